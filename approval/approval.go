@@ -0,0 +1,130 @@
+package approval
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chriskuchin/pompeii/logging"
+)
+
+type (
+	// Request an outstanding request for approval. Each listed approver gets their own callback token so a vote is
+	// attributed by the token it carries, when anyone may vote the shared Token is used and voters give their name
+	Request struct {
+		ID          string
+		Token       string
+		Tokens      map[string]string
+		Description string
+	}
+)
+
+// NewRequest returns a request with a random id and callback tokens for the approvers, or a shared token when the
+// list is empty
+func NewRequest(description string, approvers []string) (*Request, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &Request{
+		ID:          id,
+		Description: description,
+	}
+
+	if len(approvers) == 0 {
+		if request.Token, err = randomHex(16); err != nil {
+			return nil, err
+		}
+		return request, nil
+	}
+
+	request.Tokens = map[string]string{}
+	for _, approver := range approvers {
+		if request.Tokens[approver], err = randomHex(16); err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+// Link returns the callback url of the approver, or of anyone when approver is "". Opening it shows the request with
+// approve and reject buttons, only submitting one of them records a decision
+func (r *Request) Link(callbackURL, approver string) string {
+	token := r.Token
+	if approver != "" {
+		token = r.Tokens[approver]
+	}
+
+	return fmt.Sprintf("%s/approvals/%s?token=%s", strings.TrimRight(callbackURL, "/"), r.ID, url.QueryEscape(token))
+}
+
+// Approvers returns the approvers with their own callback token, sorted
+func (r *Request) Approvers() []string {
+	approvers := []string{}
+	for approver := range r.Tokens {
+		approvers = append(approvers, approver)
+	}
+	sort.Strings(approvers)
+
+	return approvers
+}
+
+// approver returns whose token was presented, "" for the shared token, and false when the token is not valid
+func (r *Request) approver(token string) (string, bool) {
+	if len(r.Tokens) == 0 {
+		return "", r.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(r.Token)) == 1
+	}
+
+	for approver, approverToken := range r.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(approverToken)) == 1 {
+			return approver, true
+		}
+	}
+
+	return "", false
+}
+
+// Await waits for the gate to be decided, polling the store if one is given, and denies the request once timeout passes
+func Await(request *Request, gate *Gate, store Store, timeout, interval time.Duration) *Result {
+	deadline := time.Now().Add(timeout)
+	for {
+		if store != nil {
+			votes, err := store.Votes(request.ID)
+			if err != nil {
+				logging.Errorf("[approval.Await] Failed to read votes: %s", err)
+			}
+
+			for _, vote := range votes {
+				if err := gate.Vote(vote); err != nil {
+					logging.Errorf("[approval.Await] Ignoring vote: %s", err)
+				}
+			}
+		}
+
+		if result := gate.Result(); result != nil {
+			return result
+		}
+
+		if !time.Now().Before(deadline) {
+			return gate.timeout()
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func randomHex(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
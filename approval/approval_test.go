@@ -0,0 +1,216 @@
+package approval
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGate(t *testing.T) {
+	tests := []struct {
+		name      string
+		approvers []string
+		required  int
+		votes     []*Vote
+		want      *Result
+	}{
+		{
+			name:  "pending",
+			votes: []*Vote{},
+			want:  nil,
+		},
+		{
+			name: "anyone_approves",
+			votes: []*Vote{
+				{Approver: "alice", Approved: true},
+			},
+			want: &Result{Approved: true, Approvers: []string{"alice"}},
+		},
+		{
+			name:      "requires_two",
+			approvers: []string{"alice", "bob"},
+			required:  2,
+			votes: []*Vote{
+				{Approver: "alice", Approved: true},
+				{Approver: "alice", Approved: true},
+				{Approver: "mallory", Approved: true},
+			},
+			want: nil,
+		},
+		{
+			name:      "reject_wins",
+			approvers: []string{"alice", "bob"},
+			votes: []*Vote{
+				{Approver: "bob", Approved: false},
+				{Approver: "alice", Approved: true},
+			},
+			want: &Result{RejectedBy: "bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := NewGate(tt.approvers, tt.required)
+			for _, vote := range tt.votes {
+				gate.Vote(vote)
+			}
+
+			if got := gate.Result(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Gate.Result() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	request := &Request{ID: "id", Tokens: map[string]string{"alice": "alice-token", "bob": "bob-token"}}
+	gate := NewGate([]string{"alice", "bob"}, 1)
+	server := httptest.NewServer(NewListener("", request, gate))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		path string
+		form url.Values
+		want int
+	}{
+		{name: "bad_token", path: "/approvals/id/approve", form: url.Values{"token": {"wrong"}}, want: http.StatusForbidden},
+		{name: "shared_token_unset", path: "/approvals/id/approve", form: url.Values{"token": {""}, "approver": {"alice"}}, want: http.StatusForbidden},
+		{name: "unknown_request", path: "/approvals/other/approve", form: url.Values{"token": {"alice-token"}}, want: http.StatusNotFound},
+		{name: "get_does_not_vote", path: "/approvals/id/approve?token=alice-token", want: http.StatusMethodNotAllowed},
+		{name: "page_bad_token", path: "/approvals/id?token=wrong", want: http.StatusForbidden},
+		{name: "page", path: "/approvals/id?token=alice-token", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			var err error
+			if tt.form != nil {
+				resp, err = http.PostForm(server.URL+tt.path, tt.form)
+			} else {
+				resp, err = http.Get(server.URL + tt.path)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	if got := gate.Result(); got != nil {
+		t.Errorf("Gate.Result() = %+v, want pending", got)
+	}
+}
+
+func TestListener_FollowLink(t *testing.T) {
+	tests := []struct {
+		name      string
+		approvers []string
+		approver  string
+		form      url.Values
+		want      []string
+	}{
+		{name: "approver_token", approvers: []string{"alice", "bob"}, approver: "bob", want: []string{"bob"}},
+		// the approver field can't claim another identity than the token's
+		{name: "approver_ignored", approvers: []string{"alice", "bob"}, approver: "bob", form: url.Values{"approver": {"alice"}}, want: []string{"bob"}},
+		{name: "anyone", form: url.Values{"approver": {"carol"}}, want: []string{"carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := NewRequest("app deploy", tt.approvers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gate := NewGate(tt.approvers, 1)
+			server := httptest.NewServer(NewListener("", request, gate))
+			defer server.Close()
+
+			link, err := url.Parse(request.Link(server.URL+"/", tt.approver))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := http.Get(link.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), request.ID+"/approve") {
+				t.Fatalf("GET %s = %d %s, want the approval page", link, resp.StatusCode, page)
+			}
+
+			if gate.Result() != nil {
+				t.Fatal("GET of the link decided the request")
+			}
+
+			// submit the approve button of the page, resolved against the link as a browser would
+			action, err := link.Parse(request.ID + "/approve")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			form := url.Values{"token": {link.Query().Get("token")}}
+			for key, values := range tt.form {
+				form[key] = values
+			}
+
+			resp, err = http.PostForm(action.String(), form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST %s = %d, want %d", action, resp.StatusCode, http.StatusOK)
+			}
+
+			if got := gate.Result(); got == nil || !got.Approved || !reflect.DeepEqual(got.Approvers, tt.want) {
+				t.Errorf("Gate.Result() = %+v, want approved by %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAwait_FileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	request := &Request{ID: "id"}
+	if err := os.MkdirAll(filepath.Join(dir, request.ID), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(&Vote{Approver: "alice", Approved: true})
+	if err := ioutil.WriteFile(filepath.Join(dir, request.ID, "alice.json"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Await(request, NewGate(nil, 1), &FileStore{Dir: dir}, time.Second, time.Millisecond)
+	if !got.Approved || !reflect.DeepEqual(got.Approvers, []string{"alice"}) {
+		t.Errorf("Await() = %+v, want approved by alice", got)
+	}
+}
+
+func TestAwait_TimeoutDenies(t *testing.T) {
+	got := Await(&Request{ID: "id"}, NewGate(nil, 1), nil, 0, time.Millisecond)
+	if got.Approved || !got.TimedOut {
+		t.Errorf("Await() = %+v, want timed out", got)
+	}
+}
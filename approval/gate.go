@@ -0,0 +1,117 @@
+package approval
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Vote a single approve or reject decision
+	Vote struct {
+		Approver string    `json:"approver"`
+		Approved bool      `json:"approved"`
+		Time     time.Time `json:"time"`
+	}
+
+	// Result the outcome of an approval request
+	Result struct {
+		Approved   bool
+		TimedOut   bool
+		Approvers  []string
+		RejectedBy string
+	}
+
+	// Gate collects votes until enough approvers have approved or any approver rejects
+	Gate struct {
+		approvers map[string]bool
+		required  int
+
+		mu        sync.Mutex
+		approvals map[string]time.Time
+		rejected  string
+	}
+)
+
+// NewGate returns a gate requiring required approvals from the listed approvers, an empty list allows anyone to vote
+func NewGate(approvers []string, required int) *Gate {
+	gate := &Gate{
+		required:  required,
+		approvals: map[string]time.Time{},
+	}
+
+	if gate.required < 1 {
+		gate.required = 1
+	}
+
+	if len(approvers) > 0 {
+		gate.approvers = map[string]bool{}
+		for _, approver := range approvers {
+			gate.approvers[approver] = true
+		}
+	}
+
+	return gate
+}
+
+// Vote records a vote, rejecting votes from anyone not on the approvers list
+func (g *Gate) Vote(vote *Vote) error {
+	if vote.Approver == "" {
+		return fmt.Errorf("approver is required")
+	}
+
+	if g.approvers != nil && !g.approvers[vote.Approver] {
+		return fmt.Errorf("%s is not an approver", vote.Approver)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !vote.Approved {
+		if g.rejected == "" {
+			g.rejected = vote.Approver
+		}
+		return nil
+	}
+
+	if _, ok := g.approvals[vote.Approver]; !ok {
+		g.approvals[vote.Approver] = vote.Time
+	}
+
+	return nil
+}
+
+// Result returns the outcome once decided or nil while the request is pending
+func (g *Gate) Result() *Result {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.rejected != "" {
+		return &Result{RejectedBy: g.rejected}
+	}
+
+	if len(g.approvals) < g.required {
+		return nil
+	}
+
+	return &Result{Approved: true, Approvers: g.approverNames()}
+}
+
+// timeout returns the default deny result recorded when nobody decided in time
+func (g *Gate) timeout() *Result {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return &Result{TimedOut: true, Approvers: g.approverNames()}
+}
+
+func (g *Gate) approverNames() []string {
+	names := []string{}
+	for name := range g.approvals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
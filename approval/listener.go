@@ -0,0 +1,152 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chriskuchin/pompeii/logging"
+)
+
+type (
+	// Listener serves the approve and reject callbacks of a single request
+	Listener struct {
+		request *Request
+		gate    *Gate
+		server  *http.Server
+	}
+)
+
+// NewListener returns a listener feeding callbacks for the request into the gate
+func NewListener(addr string, request *Request, gate *Gate) *Listener {
+	listener := &Listener{
+		request: request,
+		gate:    gate,
+	}
+
+	listener.server = &http.Server{
+		Addr:    addr,
+		Handler: listener,
+	}
+
+	return listener
+}
+
+// Start begins serving callbacks in the background
+func (l *Listener) Start() error {
+	ln, err := net.Listen("tcp", l.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := l.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logging.Errorf("[approval.Listener] %s", err)
+		}
+	}()
+
+	return nil
+}
+
+// Close stops serving callbacks
+func (l *Listener) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return l.server.Shutdown(ctx)
+}
+
+// ServeHTTP handles the approval page at GET /approvals/<id> and the decisions posted from it to
+// /approvals/<id>/approve and /approvals/<id>/reject. Only a post records a vote, so link previews and crawlers following
+// the link can't decide the request
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "approvals" || parts[1] != l.request.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		l.servePage(w, r)
+		return
+	}
+
+	if parts[2] != "approve" && parts[2] != "reject" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	approver, ok := l.request.approver(r.PostFormValue("token"))
+	if !ok {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	// a shared token doesn't identify the voter, anyone may vote so the name is taken as given
+	if approver == "" {
+		approver = strings.TrimSpace(r.PostFormValue("approver"))
+	}
+
+	vote := &Vote{
+		Approver: approver,
+		Approved: parts[2] == "approve",
+		Time:     time.Now(),
+	}
+
+	if err := l.gate.Vote(vote); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	logging.Infof("[approval.Listener] request: %s %s by %s", l.request.ID, parts[2], vote.Approver)
+	fmt.Fprintf(w, "Recorded %s from %s for %s\n", parts[2], vote.Approver, l.request.Description)
+}
+
+// servePage shows the request with approve and reject buttons posting the token back
+func (l *Listener) servePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	approver, ok := l.request.approver(token)
+	if !ok {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := pageTemplate.Execute(w, map[string]string{
+		"ID":          l.request.ID,
+		"Description": l.request.Description,
+		"Token":       token,
+		"Approver":    approver,
+	})
+	if err != nil {
+		logging.Errorf("[approval.Listener] %s", err)
+	}
+}
+
+var pageTemplate = template.Must(template.New("approval").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Approve {{ .Description }}</title></head>
+<body>
+<h1>{{ .Description }}</h1>
+<form method="post">
+<input type="hidden" name="token" value="{{ .Token }}">
+{{ if .Approver }}<p>Deciding as {{ .Approver }}</p>{{ else }}<p><label>Your name <input name="approver" required></label></p>{{ end }}
+<button formaction="{{ .ID }}/approve">Approve</button>
+<button formaction="{{ .ID }}/reject">Reject</button>
+</form>
+</body>
+</html>
+`))
@@ -0,0 +1,47 @@
+package approval
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type (
+	// Store a place votes are recorded out of band and polled while waiting for a decision
+	Store interface {
+		Votes(id string) ([]*Vote, error)
+	}
+
+	// FileStore reads votes from json files in <Dir>/<request id>/
+	FileStore struct {
+		Dir string
+	}
+)
+
+// Votes implements Store
+func (s *FileStore) Votes(id string) ([]*Vote, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, id, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	votes := []*Vote{}
+	for _, path := range paths {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		vote := &Vote{}
+		if err := json.Unmarshal(raw, vote); err != nil {
+			return nil, err
+		}
+		votes = append(votes, vote)
+	}
+
+	return votes, nil
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/chriskuchin/pompeii/config"
+)
+
+type (
+	// Record the audit trail of a single workflow run
+	Record struct {
+		RunID     string        `json:"runId"`
+		Service   string        `json:"service"`
+		Workflow  string        `json:"workflow"`
+		Actor     string        `json:"actor"`
+		Identity  string        `json:"identity,omitempty"`
+		Outcome   string        `json:"outcome"`
+		Error     string        `json:"error,omitempty"`
+		StartedAt time.Time     `json:"startedAt"`
+		Duration  time.Duration `json:"duration"`
+
+		CanaryBefore  string `json:"canaryTaskDefBefore,omitempty"`
+		CanaryAfter   string `json:"canaryTaskDefAfter,omitempty"`
+		PrimaryBefore string `json:"primaryTaskDefBefore,omitempty"`
+		PrimaryAfter  string `json:"primaryTaskDefAfter,omitempty"`
+
+		// OverrideReason why deploy windows and freezes were overridden, Overridden the closed window or freeze that was
+		OverrideReason string `json:"overrideReason,omitempty"`
+		Overridden     string `json:"overridden,omitempty"`
+	}
+
+	// Store persists audit records
+	Store interface {
+		Append(record *Record) error
+		List(service string) ([]*Record, error)
+	}
+)
+
+var (
+	// actorVariables environment variables naming who started the run, in order of preference
+	actorVariables = []string{"POMPEII_ACTOR", "GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILDKITE_BUILD_CREATOR", "USER"}
+)
+
+// NewStore returns the audit store configured for the service or nil when auditing is disabled
+func NewStore(cfg *config.Config, service string) Store {
+	auditConfig := cfg.Audit
+	if auditConfig == nil {
+		return nil
+	}
+
+	if auditConfig.Dir != "" {
+		return &LocalStore{Dir: auditConfig.Dir}
+	}
+
+	bucket := auditConfig.Bucket
+	if bucket == "" {
+		bucket = cfg.SourceBucket
+	}
+
+	if bucket == "" {
+		return nil
+	}
+
+	sess := session.New(&aws.Config{
+		Region: aws.String(cfg.GetRegion(service)),
+	})
+
+	return &S3Store{
+		Bucket: bucket,
+		Prefix: auditConfig.Prefix,
+		Svc:    s3.New(sess),
+	}
+}
+
+// Actor returns who started the run from the environment
+func Actor() string {
+	for _, variable := range actorVariables {
+		if value := os.Getenv(variable); value != "" {
+			return value
+		}
+	}
+
+	return "unknown"
+}
+
+// Identity returns the arn of the aws caller identity pompeii runs as
+func Identity(cfg *config.Config, service string) string {
+	sess := session.New(&aws.Config{
+		Region: aws.String(cfg.GetRegion(service)),
+	})
+
+	result, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return ""
+	}
+
+	return aws.StringValue(result.Arn)
+}
+
+// recordName returns the name a record is stored under, sortable by start time
+func recordName(record *Record) string {
+	return fmt.Sprintf("%s-%s.json", record.StartedAt.UTC().Format("20060102T150405Z"), record.RunID)
+}
+
+func recordKey(prefix string, record *Record) string {
+	return path.Join(prefix, record.Service, recordName(record))
+}
+
+// sortRecords orders records newest first
+func sortRecords(records []*Record) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartedAt.After(records[j].StartedAt)
+	})
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &LocalStore{Dir: dir}
+	started := time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+	older := &Record{RunID: "run1", Service: "service1", Outcome: "succeeded", StartedAt: started, Duration: time.Minute}
+	newer := &Record{RunID: "run2", Service: "service1", Outcome: "rolled-back", StartedAt: started.Add(time.Hour)}
+	other := &Record{RunID: "run3", Service: "service2", StartedAt: started}
+
+	for _, record := range []*Record{older, newer, other} {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.List("service1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, []*Record{newer, older}) {
+		t.Errorf("LocalStore.List() = %+v", got)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	cfg := &config.Config{}
+	if got := NewStore(cfg, "service1"); got != nil {
+		t.Errorf("NewStore() = %+v, want nil when auditing isn't configured", got)
+	}
+
+	cfg.Audit = &config.AuditConfig{Dir: "history"}
+	if got, ok := NewStore(cfg, "service1").(*LocalStore); !ok || got.Dir != "history" {
+		t.Errorf("NewStore() = %+v, want a local store", got)
+	}
+
+	cfg.Audit = &config.AuditConfig{Prefix: "audit"}
+	cfg.SourceBucket = "config-bucket"
+	if got, ok := NewStore(cfg, "service1").(*S3Store); !ok || got.Bucket != "config-bucket" || got.Prefix != "audit" {
+		t.Errorf("NewStore() = %+v, want an s3 store in the config bucket", got)
+	}
+}
+
+func TestActor(t *testing.T) {
+	os.Setenv("POMPEII_ACTOR", "deployer")
+	defer os.Unsetenv("POMPEII_ACTOR")
+
+	if got := Actor(); got != "deployer" {
+		t.Errorf("Actor() = %s, want deployer", got)
+	}
+}
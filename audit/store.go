@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+type (
+	// LocalStore keeps audit records as json files in <Dir>/<service>/
+	LocalStore struct {
+		Dir string
+	}
+
+	// S3Store keeps audit records as json objects under <Prefix>/<service>/
+	S3Store struct {
+		Bucket string
+		Prefix string
+		Svc    s3iface.S3API
+	}
+)
+
+// Append implements Store
+func (s *LocalStore) Append(record *Record) error {
+	dir := filepath.Join(s.Dir, record.Service)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, recordName(record)), raw, 0644)
+}
+
+// List implements Store
+func (s *LocalStore) List(service string) ([]*Record, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, service, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*Record{}
+	for _, p := range paths {
+		raw, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		record := &Record{}
+		if err := json.Unmarshal(raw, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	sortRecords(records)
+	return records, nil
+}
+
+// Append implements Store
+func (s *S3Store) Append(record *Record) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Svc.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(recordKey(s.Prefix, record)),
+		Body:        bytes.NewReader(raw),
+		ContentType: aws.String("application/json"),
+	})
+
+	return err
+}
+
+// List implements Store
+func (s *S3Store) List(service string) ([]*Record, error) {
+	keys := []*string{}
+	err := s.Svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(path.Join(s.Prefix, service) + "/"),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, object.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*Record{}
+	for _, key := range keys {
+		result, err := s.Svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    key,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := ioutil.ReadAll(result.Body)
+		result.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		record := &Record{}
+		if err := json.Unmarshal(raw, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	sortRecords(records)
+	return records, nil
+}
@@ -2,36 +2,56 @@ package config
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/prometheus/common/log"
-	"gopkg.in/yaml.v2"
+	"github.com/chriskuchin/pompeii/logging"
 )
 
 type (
 	// Config config object
 	Config struct {
-		ClusterARN string                    `yaml:"cluster-arn"`
-		Region     string                    `yaml:"region"`
-		Services   map[string]*ServiceConfig `yaml:"services"`
-		Workflows  WorkflowConfig            `yaml:"workflows"`
+		ClusterARN string                     `yaml:"cluster-arn"`
+		Region     string                     `yaml:"region"`
+		Services   map[string]*ServiceConfig  `yaml:"services"`
+		Workflows  WorkflowConfig             `yaml:"workflows"`
+		Notifiers  map[string]*NotifierConfig `yaml:"notifiers"`
+		Metrics    *MetricsConfig             `yaml:"metrics"`
+		Audit      *AuditConfig               `yaml:"audit"`
+		State      *StateConfig               `yaml:"state"`
+		Include    []string                   `yaml:"include"`
+
+		// Defaults settings inherited by every service that doesn't set them itself
+		Defaults *ServiceConfig `yaml:"defaults"`
+
+		// SourceBucket the bucket the config was loaded from
+		SourceBucket string `yaml:"-"`
 	}
 
 	// ServiceConfig test
 	ServiceConfig struct {
-		ClusterARN              string        `yaml:"cluster-arn"`
-		ListenerARN             string        `yaml:"listener-rule-arn"`
-		Region                  string        `yaml:"region"`
-		Timeout                 time.Duration `yaml:"deploy-timeout"`
-		ValidationTask          string        `yaml:"valdation-task"`
-		ValidationTaskContainer string        `yaml:"validation-task-container"`
+		ClusterARN              string                `yaml:"cluster-arn"`
+		Cluster                 string                `yaml:"cluster"`
+		ListenerARN             string                `yaml:"listener-rule-arn"`
+		ListenerRule            *ListenerRuleSelector `yaml:"listener-rule"`
+		Region                  string                `yaml:"region"`
+		Timeout                 time.Duration         `yaml:"deploy-timeout"`
+		PollInterval            time.Duration         `yaml:"poll-interval"`
+		Workflow                string                `yaml:"workflow"`
+		Workflows               WorkflowConfig        `yaml:"workflows"`
+		MaxFailedTasks          int64                 `yaml:"max-failed-tasks"`
+		ValidationTask          string                `yaml:"valdation-task"`
+		ValidationTaskContainer string                `yaml:"validation-task-container"`
+		ValidationTaskConfig    *TaskConfig           `yaml:"validation-task-config"`
+		HealthCheck             *HealthCheck          `yaml:"health-check"`
+		SuspendScaling          *bool                 `yaml:"suspend-scaling"`
+		Notify                  []string              `yaml:"notify"`
+		Approval                *ApprovalConfig       `yaml:"approval"`
+
+		// DeployWindows when workflows may run, any time when unset, and Freezes when they may not regardless of the windows
+		DeployWindows []*DeployWindow `yaml:"deploy-windows"`
+		Freezes       []*Freeze       `yaml:"freezes"`
 
 		Canary  *PoolConfig `yaml:"canary"`
 		Primary *PoolConfig `yaml:"primary"`
@@ -39,66 +59,137 @@ type (
 
 	// PoolConfig test
 	PoolConfig struct {
-		TargetGroupARN string `yaml:"tg-arn"`
-		Service        string `yaml:"ecs-service"`
+		TargetGroupARN  string            `yaml:"tg-arn"`
+		TargetGroupName string            `yaml:"tg-name"`
+		TargetGroupTags map[string]string `yaml:"tg-tags"`
+		Service         string            `yaml:"ecs-service"`
+		MinCapacity     int64             `yaml:"min-capacity"`
+		MaxCapacity     int64             `yaml:"max-capacity"`
 	}
 
-	WorkflowConfig map[string][]*Action
-)
+	// ListenerRuleSelector finds a listener rule by its load balancer, listener port and either its priority or path condition
+	ListenerRuleSelector struct {
+		LoadBalancer string `yaml:"load-balancer"`
+		Port         int64  `yaml:"port"`
+		Priority     string `yaml:"priority"`
+		Path         string `yaml:"path"`
+	}
 
-// NewConfigFromFile loads a yaml file intoa  config struct
-func NewConfigFromFile(path string) *Config {
-	configFile, err := os.Open(path)
-	if err != nil {
-		return nil
+	// TaskConfig controls how validation tasks are launched
+	TaskConfig struct {
+		LaunchType               string                    `yaml:"launch-type"`
+		CapacityProviderStrategy []*CapacityProviderConfig `yaml:"capacity-provider-strategy"`
+		Subnets                  []string                  `yaml:"subnets"`
+		SecurityGroups           []string                  `yaml:"security-groups"`
+		AssignPublicIP           *bool                     `yaml:"assign-public-ip"`
+		PlatformVersion          string                    `yaml:"platform-version"`
+		Environment              map[string]string         `yaml:"environment"`
+		TaskRoleARN              string                    `yaml:"task-role-arn"`
 	}
 
-	rawYaml, err := ioutil.ReadAll(configFile)
-	if err != nil {
-		return nil
+	// CapacityProviderConfig a single entry of a capacity provider strategy
+	CapacityProviderConfig struct {
+		CapacityProvider string `yaml:"capacity-provider"`
+		Base             int64  `yaml:"base"`
+		Weight           int64  `yaml:"weight"`
 	}
 
-	config := &Config{}
-	err = yaml.Unmarshal(rawYaml, config)
-	if err != nil {
-		return nil
+	// HealthCheck thresholds a target group must meet before and after receiving traffic
+	HealthCheck struct {
+		MinHealthy         int64         `yaml:"min-healthy"`
+		MinHealthyFraction float64       `yaml:"min-healthy-fraction"`
+		SettlePeriod       time.Duration `yaml:"settle-period"`
+		Interval           time.Duration `yaml:"interval"`
 	}
-	return config
-}
 
-func NewConfigFromS3(key, bucket string) *Config {
-	svc := s3.New(session.New())
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+	// NotifierConfig a destination for workflow lifecycle events
+	NotifierConfig struct {
+		Type     string            `yaml:"type"`
+		URL      string            `yaml:"url"`
+		Secret   string            `yaml:"secret"`
+		Headers  map[string]string `yaml:"headers"`
+		Template string            `yaml:"template"`
+		Events   []string          `yaml:"events"`
 	}
 
-	result, err := svc.GetObject(input)
+	// ApprovalConfig how approval validators request and collect decisions
+	ApprovalConfig struct {
+		Notifiers    []string      `yaml:"notifiers"`
+		CallbackURL  string        `yaml:"callback-url"`
+		Listen       string        `yaml:"listen"`
+		StoreDir     string        `yaml:"store-dir"`
+		Approvers    []string      `yaml:"approvers"`
+		Required     int           `yaml:"required-approvals"`
+		Timeout      time.Duration `yaml:"timeout"`
+		PollInterval time.Duration `yaml:"poll-interval"`
+	}
+
+	// MetricsConfig where pompeii pushes metrics about its own deploy activity
+	MetricsConfig struct {
+		PushgatewayURL string            `yaml:"pushgateway-url"`
+		Job            string            `yaml:"job"`
+		Grouping       map[string]string `yaml:"grouping"`
+	}
+
+	// AuditConfig where the audit trail of workflow runs is kept, the config bucket is used when neither bucket nor dir is set
+	AuditConfig struct {
+		Bucket string `yaml:"bucket"`
+		Prefix string `yaml:"prefix"`
+		Dir    string `yaml:"dir"`
+	}
+
+	// StateConfig where paused runs are kept until they are continued or aborted, the config bucket is used when neither
+	// bucket nor dir is set and DefaultStateDir when the config wasn't loaded from s3
+	StateConfig struct {
+		Bucket string `yaml:"bucket"`
+		Prefix string `yaml:"prefix"`
+		Dir    string `yaml:"dir"`
+	}
+
+	WorkflowConfig map[string]*WorkflowDefinition
+)
+
+const (
+	// DefaultDeployTimeout how long a deployment may take before it is considered failed
+	DefaultDeployTimeout = 15 * time.Minute
+	// DefaultPollInterval how often deployments and tasks are polled for progress
+	DefaultPollInterval = 30 * time.Second
+	// DefaultWorkflow the workflow deploy runs when neither the command line nor the service names one
+	DefaultWorkflow = "default"
+	// DefaultMaxFailedTasks number of failed tasks tolerated before a deployment is considered failed
+	DefaultMaxFailedTasks int64 = 3
+	// DefaultHealthCheckInterval how often target health is polled while a shift settles
+	DefaultHealthCheckInterval = 10 * time.Second
+	// DefaultApprovalTimeout how long an approval waits before it is denied
+	DefaultApprovalTimeout = 30 * time.Minute
+	// DefaultApprovalPollInterval how often approval decisions are checked
+	DefaultApprovalPollInterval = 5 * time.Second
+	// DefaultStateDir where paused runs are kept when neither a state location nor a config bucket is known
+	DefaultStateDir = ".pompeii/runs"
+	// DefaultStatePrefix the key prefix of paused runs kept in s3
+	DefaultStatePrefix = "pompeii/runs"
+)
+
+// NewConfigFromFile loads a yaml file intoa  config struct, expanding templates and environment variables and merging included fragments
+func NewConfigFromFile(path string, vars map[string]string) *Config {
+	config, err := newLoader(vars).load(path)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchKey:
-				fmt.Println(s3.ErrCodeNoSuchKey, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+		logging.Errorf("[config.NewConfigFromFile] %s", err)
 		return nil
 	}
 
-	body, err := ioutil.ReadAll(result.Body)
+	return config
+}
+
+// NewConfigFromS3 loads a yaml object from s3 into a config struct, expanding templates and environment variables and merging included fragments
+func NewConfigFromS3(key, bucket string, vars map[string]string) *Config {
+	config, err := newLoader(vars).load(fmt.Sprintf("%s%s/%s", s3Scheme, bucket, key))
 	if err != nil {
+		logging.Errorf("[config.NewConfigFromS3] %s", err)
 		return nil
 	}
 
-	log.Debugf("BODY####\n%s\n", body)
-	config := &Config{}
-	yaml.Unmarshal(body, config)
-
+	config.SourceBucket = bucket
 	return config
 }
 
@@ -126,6 +217,17 @@ func (c *Config) GetPrimaryTargetGroupARN(service string) string {
 	return c.Services[service].Primary.TargetGroupARN
 }
 
+// GetTargetGroupARN returns the target group arn of the given pool
+func (c *Config) GetTargetGroupARN(service, pool string) string {
+	if strings.ToLower(pool) == "canary" {
+		return c.GetCanaryTargetGroupARN(service)
+	} else if strings.ToLower(pool) == "primary" {
+		return c.GetPrimaryTargetGroupARN(service)
+	}
+
+	return ""
+}
+
 // IsCanaryTargetGroup tests whether a provided arn is the canary pools target group
 func (c *Config) IsCanaryTargetGroup(service, arn string) bool {
 	return c.GetCanaryTargetGroupARN(service) == arn
@@ -150,6 +252,162 @@ func (c *Config) GetServiceValidationTask(service string) string {
 	return c.Services[service].ValidationTask
 }
 
+// GetValidationTaskConfig returns the launch settings for the services validation task
+func (c *Config) GetValidationTaskConfig(service string) *TaskConfig {
+	if c.Services[service].ValidationTaskConfig == nil {
+		return &TaskConfig{}
+	}
+
+	return c.Services[service].ValidationTaskConfig
+}
+
+// GetMaxFailedTasks returns how many tasks may fail during a deployment before it is abandoned
+func (c *Config) GetMaxFailedTasks(service string) int64 {
+	if c.Services[service].MaxFailedTasks > 0 {
+		return c.Services[service].MaxFailedTasks
+	}
+
+	return DefaultMaxFailedTasks
+}
+
+// GetSuspendScaling returns whether the auto scaling of the services pools is suspended while it deploys
+func (c *Config) GetSuspendScaling(service string) bool {
+	suspend := c.Services[service].SuspendScaling
+	return suspend != nil && *suspend
+}
+
+// GetTimeout returns how long the services deployments may take
+func (c *Config) GetTimeout(service string) time.Duration {
+	if c.Services[service].Timeout > 0 {
+		return c.Services[service].Timeout
+	}
+
+	return DefaultDeployTimeout
+}
+
+// GetPollInterval returns how often the services deployments and tasks are checked on
+func (c *Config) GetPollInterval(service string) time.Duration {
+	if c.Services[service].PollInterval > 0 {
+		return c.Services[service].PollInterval
+	}
+
+	return DefaultPollInterval
+}
+
+// GetWorkflow returns the name of the workflow the service deploys with
+func (c *Config) GetWorkflow(service string) string {
+	if c.Services[service].Workflow != "" {
+		return c.Services[service].Workflow
+	}
+
+	return DefaultWorkflow
+}
+
+// GetWorkflowDefinition looks up a workflow in the services own workflows falling back to the shared workflows
+func (c *Config) GetWorkflowDefinition(service, name string) *WorkflowDefinition {
+	if definition, ok := c.Services[service].Workflows[name]; ok {
+		return definition
+	}
+
+	return c.Workflows[name]
+}
+
+// GetHealthCheck returns the services target health thresholds, requiring a single healthy target by default
+func (c *Config) GetHealthCheck(service string) *HealthCheck {
+	check := &HealthCheck{}
+	if c.Services[service].HealthCheck != nil {
+		*check = *c.Services[service].HealthCheck
+	}
+
+	if check.MinHealthy == 0 && check.MinHealthyFraction == 0 {
+		check.MinHealthy = 1
+	}
+
+	if check.Interval == 0 {
+		check.Interval = DefaultHealthCheckInterval
+	}
+
+	return check
+}
+
+// GetPoolConfig returns the configuration of the given pool
+func (c *Config) GetPoolConfig(service, pool string) *PoolConfig {
+	if strings.ToLower(pool) == "canary" {
+		return c.Services[service].Canary
+	} else if strings.ToLower(pool) == "primary" {
+		return c.Services[service].Primary
+	}
+
+	return nil
+}
+
+// GetClusterName returns the services cluster name, stripping the arn prefix if one was configured
+func (c *Config) GetClusterName(service string) string {
+	cluster := c.Services[service].ClusterARN
+	if index := strings.LastIndex(cluster, "/"); index >= 0 {
+		return cluster[index+1:]
+	}
+
+	return cluster
+}
+
+// GetNotifiers returns the notifiers a service routes its events to, defaulting to every configured notifier
+func (c *Config) GetNotifiers(service string) []string {
+	if c.Services[service] != nil && len(c.Services[service].Notify) > 0 {
+		return c.Services[service].Notify
+	}
+
+	names := []string{}
+	for name := range c.Notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetApprovalConfig returns the services approval settings with defaults applied
+func (c *Config) GetApprovalConfig(service string) *ApprovalConfig {
+	approval := &ApprovalConfig{}
+	if c.Services[service].Approval != nil {
+		*approval = *c.Services[service].Approval
+	}
+
+	if approval.Timeout == 0 {
+		approval.Timeout = DefaultApprovalTimeout
+	}
+
+	if approval.PollInterval == 0 {
+		approval.PollInterval = DefaultApprovalPollInterval
+	}
+
+	return approval
+}
+
+// GetStateConfig returns where paused runs are kept with defaults applied
+func (c *Config) GetStateConfig() *StateConfig {
+	state := &StateConfig{}
+	if c.State != nil {
+		*state = *c.State
+	}
+
+	if state.Dir != "" {
+		return state
+	}
+
+	if state.Bucket == "" {
+		state.Bucket = c.SourceBucket
+	}
+
+	if state.Bucket == "" {
+		state.Dir = DefaultStateDir
+	} else if state.Prefix == "" {
+		state.Prefix = DefaultStatePrefix
+	}
+
+	return state
+}
+
 func (c *Config) GetECSServiceName(service, pool string) string {
 	if strings.ToLower(pool) == "canary" {
 		return c.Services[service].Canary.Service
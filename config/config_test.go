@@ -1,13 +1,17 @@
 package config
 
 import (
+	"os"
 	"reflect"
 	"testing"
 )
 
 func TestNewConfigFromFile(t *testing.T) {
+	assignPublicIP := true
+
 	type args struct {
 		path string
+		vars map[string]string
 	}
 	tests := []struct {
 		name string
@@ -34,33 +38,113 @@ func TestNewConfigFromFile(t *testing.T) {
 					},
 				},
 				Workflows: WorkflowConfig{
-					"validate": []*Action{
-						{
-							Type:   ValidatePool,
-							Target: "task",
+					"validate": {
+						Steps: []*Action{
+							{
+								Type:   ValidatePool,
+								Target: "task",
+							},
+							{
+								Type:   ValidatePool,
+								Target: "prompt",
+							},
+							{
+								Type:   TrafficShift,
+								Target: "primary",
+								Ratio:  100,
+							},
+							{
+								Type:   UpdatePool,
+								Target: "canary",
+								Count:  1,
+							},
 						},
-						{
-							Type:   ValidatePool,
-							Target: "prompt",
+					},
+				},
+			},
+		},
+		{
+			name: "fargate",
+			args: args{
+				path: "testdata/fargate.yml",
+			},
+			want: &Config{
+				Services: map[string]*ServiceConfig{
+					"service1": {
+						ListenerARN:             "listener-rule-arn-service1",
+						ValidationTask:          "validation-task-service1",
+						ValidationTaskContainer: "smoke",
+						ValidationTaskConfig: &TaskConfig{
+							LaunchType:      "FARGATE",
+							PlatformVersion: "1.4.0",
+							Subnets:         []string{"subnet-a", "subnet-b"},
+							SecurityGroups:  []string{"sg-a"},
+							AssignPublicIP:  &assignPublicIP,
+							Environment: map[string]string{
+								"TARGET": "canary",
+							},
+							TaskRoleARN: "task-role-arn-service1",
 						},
-						{
-							Type:   TrafficShift,
-							Target: "primary",
-							Ratio:  100,
+						Canary: &PoolConfig{
+							TargetGroupARN: "tg-arn-canary-service1",
+							Service:        "service1-canary",
 						},
-						{
-							Type:   UpdatePool,
-							Target: "canary",
-							Count:  1,
+						Primary: &PoolConfig{
+							TargetGroupARN: "tg-arn-primary-service1",
+							Service:        "service1",
 						},
 					},
 				},
 			},
 		},
+		{
+			name: "templated",
+			args: args{
+				path: "testdata/templated.yml",
+				vars: map[string]string{
+					"environment": "staging",
+				},
+			},
+			want: &Config{
+				Region: "us-east-1",
+				Services: map[string]*ServiceConfig{
+					"service1": {
+						Region:      "us-east-1",
+						ClusterARN:  "arn:aws:ecs:us-east-1:123456789012:cluster/staging",
+						ListenerARN: "listener-rule-arn-staging",
+						Canary: &PoolConfig{
+							TargetGroupARN: "tg-arn-canary-staging",
+							Service:        "service1-canary",
+						},
+						Primary: &PoolConfig{
+							TargetGroupARN: "tg-arn-primary-staging",
+							Service:        "service1",
+						},
+					},
+				},
+				Notifiers: map[string]*NotifierConfig{
+					"deploys": {
+						Type:     "slack",
+						URL:      "https://hooks.slack.com/staging",
+						Template: "{{ .Service }} {{ .Type }} ${literal}",
+					},
+				},
+			},
+		},
+		{
+			name: "templated_missing_var",
+			args: args{
+				path: "testdata/templated.yml",
+			},
+			want: nil,
+		},
 	}
+	os.Setenv("POMPEII_TEST_ACCOUNT", "123456789012")
+	defer os.Unsetenv("POMPEII_TEST_ACCOUNT")
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewConfigFromFile(tt.args.path); !reflect.DeepEqual(got, tt.want) {
+			if got := NewConfigFromFile(tt.args.path, tt.args.vars); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewConfigFromFile() = %v, want %v", got, tt.want)
 			}
 		})
@@ -127,6 +211,44 @@ func TestConfig_GetRegion(t *testing.T) {
 	}
 }
 
+func TestConfig_GetStateConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        *StateConfig
+		sourceBucket string
+		want         *StateConfig
+	}{
+		{
+			name: "local_default",
+			want: &StateConfig{Dir: DefaultStateDir},
+		},
+		{
+			name:         "config_bucket",
+			sourceBucket: "config-bucket",
+			want:         &StateConfig{Bucket: "config-bucket", Prefix: DefaultStatePrefix},
+		},
+		{
+			name:         "dir",
+			state:        &StateConfig{Dir: "runs"},
+			sourceBucket: "config-bucket",
+			want:         &StateConfig{Dir: "runs"},
+		},
+		{
+			name:  "bucket",
+			state: &StateConfig{Bucket: "state-bucket", Prefix: "paused"},
+			want:  &StateConfig{Bucket: "state-bucket", Prefix: "paused"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{State: tt.state, SourceBucket: tt.sourceBucket}
+			if got := c.GetStateConfig(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Config.GetStateConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestConfig_GetListenerRuleARN(t *testing.T) {
 	type fields struct {
 		ClusterARN string
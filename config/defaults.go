@@ -0,0 +1,123 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyDefaults fills every unset service field from the defaults block, and the cluster and region from the root of the config.
+// Since only unset fields are filled a default of true can't be switched off by a service
+func (c *Config) applyDefaults() {
+	base := &ServiceConfig{
+		ClusterARN: c.ClusterARN,
+		Region:     c.Region,
+	}
+	if c.Defaults != nil {
+		mergeDefaults(reflect.ValueOf(base).Elem(), reflect.ValueOf(c.Defaults).Elem())
+	}
+
+	for _, service := range c.Services {
+		if service == nil {
+			continue
+		}
+
+		defaults := *base
+		if service.Cluster != "" {
+			// a cluster name takes precedence over an inherited arn
+			defaults.ClusterARN = ""
+		}
+		if service.ListenerRule != nil {
+			defaults.ListenerARN = ""
+		}
+
+		mergeDefaults(reflect.ValueOf(service).Elem(), reflect.ValueOf(&defaults).Elem())
+	}
+}
+
+// mergeDefaults sets the zero valued fields of dst to their value in defaults, merging nested structs field by field.
+// Struct pointers are copied so services never share a struct with the defaults or each other. Bools are pointers so
+// a service can set false over a true default
+func mergeDefaults(dst, defaults reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field, fallback := dst.Field(i), defaults.Field(i)
+		if fallback.IsZero() {
+			continue
+		}
+
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+			merged := reflect.New(field.Type().Elem())
+			if !field.IsNil() {
+				merged.Elem().Set(field.Elem())
+			}
+			mergeDefaults(merged.Elem(), fallback.Elem())
+			field.Set(merged)
+			continue
+		}
+
+		if field.IsZero() {
+			field.Set(fallback)
+		}
+	}
+}
+
+// GetServiceConfig returns the effective settings of a service with every built in default applied
+func (c *Config) GetServiceConfig(service string) *ServiceConfig {
+	effective := *c.Services[service]
+	effective.Region = c.GetRegion(service)
+	effective.Timeout = c.GetTimeout(service)
+	effective.PollInterval = c.GetPollInterval(service)
+	effective.MaxFailedTasks = c.GetMaxFailedTasks(service)
+	effective.Workflow = c.GetWorkflow(service)
+	effective.HealthCheck = c.GetHealthCheck(service)
+	effective.Notify = c.GetNotifiers(service)
+	if effective.Approval != nil {
+		effective.Approval = c.GetApprovalConfig(service)
+	}
+
+	return &effective
+}
+
+// MarshalYAML writes the service config with readable durations, leaving out unset fields
+func (s *ServiceConfig) MarshalYAML() (interface{}, error) {
+	return readable(reflect.ValueOf(s).Elem()), nil
+}
+
+// readable converts structs into yaml maps keyed by their yaml tags, dropping zero values and empty collections and formatting durations
+func readable(value reflect.Value) interface{} {
+	switch {
+	case value.Type() == durationType:
+		return time.Duration(value.Int()).String()
+	case value.Kind() == reflect.Ptr:
+		return readable(value.Elem())
+	case value.Kind() == reflect.Slice:
+		items := []interface{}{}
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, readable(value.Index(i)))
+		}
+		return items
+	case value.Kind() != reflect.Struct:
+		return value.Interface()
+	}
+
+	node := yaml.MapSlice{}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		name := strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" || field.IsZero() {
+			continue
+		}
+
+		if (field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() == 0 {
+			continue
+		}
+
+		node = append(node, yaml.MapItem{Key: name, Value: readable(field)})
+	}
+
+	return node
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_applyDefaults(t *testing.T) {
+	config := NewConfigFromFile("testdata/defaults.yml", nil)
+	if config == nil {
+		t.Fatal("NewConfigFromFile() = nil")
+	}
+
+	enabled, disabled := true, false
+
+	want := map[string]*ServiceConfig{
+		"service1": {
+			ClusterARN:     "arn:aws:ecs:us-east-1:123456789012:cluster/shared",
+			ListenerARN:    "listener-rule-arn-service1",
+			Region:         "us-east-1",
+			Timeout:        20 * time.Minute,
+			PollInterval:   15 * time.Second,
+			Workflow:       "canary",
+			ValidationTask: "smoke-test",
+			SuspendScaling: &enabled,
+			ValidationTaskConfig: &TaskConfig{
+				AssignPublicIP: &enabled,
+			},
+			HealthCheck: &HealthCheck{
+				MinHealthy:   2,
+				SettlePeriod: time.Minute,
+			},
+		},
+		"service2": {
+			Cluster:        "dedicated",
+			Region:         "us-east-1",
+			Timeout:        5 * time.Minute,
+			PollInterval:   15 * time.Second,
+			Workflow:       "blue-green",
+			ValidationTask: "smoke-test",
+			// false overrides the true defaults
+			SuspendScaling: &disabled,
+			ValidationTaskConfig: &TaskConfig{
+				AssignPublicIP: &disabled,
+			},
+			HealthCheck: &HealthCheck{
+				MinHealthy:         2,
+				MinHealthyFraction: 0.5,
+				SettlePeriod:       time.Minute,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(config.Services, want) {
+		t.Errorf("applyDefaults() = %+v, want %+v", config.Services, want)
+	}
+
+	if config.Services["service1"].HealthCheck == config.Defaults.HealthCheck {
+		t.Error("applyDefaults() shared the health check of the defaults")
+	}
+}
+
+func TestServiceConfig_MarshalYAML(t *testing.T) {
+	config := &Config{
+		Services: map[string]*ServiceConfig{
+			"service1": {
+				ClusterARN: "cluster",
+				Timeout:    10 * time.Minute,
+				Canary:     &PoolConfig{Service: "service1-canary"},
+			},
+		},
+	}
+
+	got, err := yaml.Marshal(config.GetServiceConfig("service1"))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `cluster-arn: cluster
+deploy-timeout: 10m0s
+poll-interval: 30s
+workflow: default
+max-failed-tasks: 3
+health-check:
+  min-healthy: 1
+  interval: 10s
+canary:
+  ecs-service: service1-canary
+`
+	if string(got) != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+}
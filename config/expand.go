@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	// templateLeft and templateRight delimit config template actions, leaving {{ }} to notifier templates.
+	// A literal [[ is written as [[ "[[" ]]
+	templateLeft  = "[["
+	templateRight = "]]"
+)
+
+var (
+	// envPattern matches ${NAME} and ${NAME:-default}, or $${ which is a literal ${
+	envPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// Expand renders the raw config as a go template over vars, with actions delimited by [[ ]], and then substitutes
+// ${ENV_VAR} references. {{ }} is left alone for the templates of notifiers
+func Expand(raw []byte, vars map[string]string) ([]byte, error) {
+	rendered, err := renderTemplate(raw, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandEnv(rendered)
+}
+
+func renderTemplate(raw []byte, vars map[string]string) ([]byte, error) {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+
+	tmpl, err := template.New("config").Delims(templateLeft, templateRight).Option("missingkey=error").Funcs(template.FuncMap{
+		"env": os.Getenv,
+		"default": func(fallback, value string) string {
+			if value == "" {
+				return fallback
+			}
+			return value
+		},
+	}).Parse(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, vars); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func expandEnv(raw []byte) ([]byte, error) {
+	missing := []string{}
+	expanded := envPattern.ReplaceAllFunc(raw, func(match []byte) []byte {
+		groups := envPattern.FindSubmatch(match)
+		if len(groups[1]) == 0 {
+			return []byte("${")
+		}
+
+		name := string(groups[1])
+
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			return []byte(value)
+		}
+
+		if len(groups[2]) > 0 {
+			return groups[3]
+		}
+
+		missing = append(missing, name)
+		return match
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("undefined environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	os.Setenv("POMPEII_TEST_SET", "value")
+	defer os.Unsetenv("POMPEII_TEST_SET")
+
+	tests := []struct {
+		name    string
+		raw     string
+		vars    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "env", raw: "a: ${POMPEII_TEST_SET}", want: "a: value"},
+		{name: "env_default", raw: "a: ${POMPEII_TEST_UNSET:-fallback}", want: "a: fallback"},
+		{name: "env_empty_default", raw: "a: ${POMPEII_TEST_UNSET:-}", want: "a: "},
+		{name: "env_missing", raw: "a: ${POMPEII_TEST_UNSET}", wantErr: true},
+		{name: "env_literal", raw: "a: $${POMPEII_TEST_UNSET}", want: "a: ${POMPEII_TEST_UNSET}"},
+		{name: "var", raw: "a: [[ .env ]]", vars: map[string]string{"env": "prod"}, want: "a: prod"},
+		{name: "var_missing", raw: "a: [[ .env ]]", wantErr: true},
+		{name: "env_func", raw: `a: [[ env "POMPEII_TEST_UNSET" | default "dev" ]]`, want: "a: dev"},
+		{name: "template_literal", raw: `a: [[ "[[" ]]x]]`, want: "a: [[x]]"},
+		{name: "notifier_template", raw: "template: \"{{ .Service }} {{ .Type }}\"", want: "template: \"{{ .Service }} {{ .Type }}\""},
+		{name: "untouched", raw: "a: $HOME", want: "a: $HOME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand([]byte(tt.raw), tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && string(got) != tt.want {
+				t.Errorf("Expand() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
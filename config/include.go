@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/chriskuchin/pompeii/logging"
+	"gopkg.in/yaml.v2"
+)
+
+const s3Scheme = "s3://"
+
+// loader reads a config and every fragment it includes, merging them into a single config
+type loader struct {
+	vars    map[string]string
+	s3Svc   s3iface.S3API
+	config  *Config
+	origins map[string]string
+	loading map[string]bool
+	// loaded the sources already merged, a source matched by more than one include is only merged once
+	loaded map[string]bool
+}
+
+func newLoader(vars map[string]string) *loader {
+	return &loader{
+		vars:    vars,
+		config:  &Config{},
+		origins: map[string]string{},
+		loading: map[string]bool{},
+		loaded:  map[string]bool{},
+	}
+}
+
+// load reads source, a local path or an s3://bucket/key url, along with its includes
+func (l *loader) load(source string) (*Config, error) {
+	if err := l.include(source); err != nil {
+		return nil, err
+	}
+
+	l.config.applyDefaults()
+	if err := l.config.validate(); err != nil {
+		return nil, err
+	}
+
+	return l.config, nil
+}
+
+func (l *loader) include(source string) error {
+	if l.loading[source] {
+		return fmt.Errorf("include cycle at %s", source)
+	}
+	if l.loaded[source] {
+		return nil
+	}
+	l.loaded[source] = true
+
+	l.loading[source] = true
+	defer delete(l.loading, source)
+
+	raw, err := l.read(source)
+	if err != nil {
+		return err
+	}
+
+	fragment, err := parseConfig(raw, l.vars, source)
+	if err != nil {
+		return err
+	}
+
+	if err := l.merge(fragment, source); err != nil {
+		return err
+	}
+
+	for _, pattern := range fragment.Include {
+		sources, err := l.resolve(source, pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, included := range sources {
+			if err := l.include(included); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *loader) read(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, s3Scheme) {
+		return ioutil.ReadFile(source)
+	}
+
+	bucket, key := splitS3URL(source)
+	if l.s3Svc == nil {
+		l.s3Svc = s3.New(session.New())
+	}
+
+	result, err := l.s3Svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		logger := logging.WithFields(logging.Fields{"bucket": bucket, "key": key})
+		if reqErr, ok := err.(awserr.RequestFailure); ok {
+			logger = logger.WithField(logging.RequestID, reqErr.RequestID())
+		}
+
+		if aerr, ok := err.(awserr.Error); ok {
+			logger.WithField("aws_error_code", aerr.Code()).Error(aerr.Message())
+		} else {
+			logger.Error(err)
+		}
+		return nil, fmt.Errorf("failed to read %s: %s", source, err)
+	}
+	defer result.Body.Close()
+
+	body, err := ioutil.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Debugf("BODY####\n%s\n", body)
+	return body, nil
+}
+
+// resolve expands an include pattern relative to the file that declared it. Local patterns are globs,
+// includes of an s3 config are keys relative to the including key, or to the bucket when they start with a /, unless
+// given as a full s3:// url
+func (l *loader) resolve(parent, pattern string) ([]string, error) {
+	if strings.HasPrefix(pattern, s3Scheme) {
+		return []string{pattern}, nil
+	}
+
+	if strings.HasPrefix(parent, s3Scheme) {
+		bucket, key := splitS3URL(parent)
+		if !strings.HasPrefix(pattern, "/") {
+			pattern = path.Join(path.Dir(key), pattern)
+		}
+		return []string{s3Scheme + path.Join(bucket, strings.TrimPrefix(pattern, "/"))}, nil
+	}
+
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(parent), pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include %s in %s: %s", pattern, parent, err)
+	}
+
+	if len(matches) == 0 && !hasGlobMeta(pattern) {
+		return nil, fmt.Errorf("included file %s not found, included from %s", pattern, parent)
+	}
+
+	return matches, nil
+}
+
+// merge adds a fragment to the loaded config, failing if a key was already defined by another source
+func (l *loader) merge(fragment *Config, source string) error {
+	config := l.config
+
+	if fragment.ClusterARN != "" {
+		if err := l.claim("cluster-arn", source); err != nil {
+			return err
+		}
+		config.ClusterARN = fragment.ClusterARN
+	}
+
+	if fragment.Region != "" {
+		if err := l.claim("region", source); err != nil {
+			return err
+		}
+		config.Region = fragment.Region
+	}
+
+	if fragment.Metrics != nil {
+		if err := l.claim("metrics", source); err != nil {
+			return err
+		}
+		config.Metrics = fragment.Metrics
+	}
+
+	if fragment.Audit != nil {
+		if err := l.claim("audit", source); err != nil {
+			return err
+		}
+		config.Audit = fragment.Audit
+	}
+
+	if fragment.State != nil {
+		if err := l.claim("state", source); err != nil {
+			return err
+		}
+		config.State = fragment.State
+	}
+
+	if fragment.Defaults != nil {
+		if err := l.claim("defaults", source); err != nil {
+			return err
+		}
+		config.Defaults = fragment.Defaults
+	}
+
+	for name, service := range fragment.Services {
+		if err := l.claim("services."+name, source); err != nil {
+			return err
+		}
+
+		if config.Services == nil {
+			config.Services = map[string]*ServiceConfig{}
+		}
+		config.Services[name] = service
+	}
+
+	for name, steps := range fragment.Workflows {
+		if err := l.claim("workflows."+name, source); err != nil {
+			return err
+		}
+
+		if config.Workflows == nil {
+			config.Workflows = WorkflowConfig{}
+		}
+		config.Workflows[name] = steps
+	}
+
+	for name, notifier := range fragment.Notifiers {
+		if err := l.claim("notifiers."+name, source); err != nil {
+			return err
+		}
+
+		if config.Notifiers == nil {
+			config.Notifiers = map[string]*NotifierConfig{}
+		}
+		config.Notifiers[name] = notifier
+	}
+
+	config.Include = append(config.Include, fragment.Include...)
+
+	return nil
+}
+
+func (l *loader) claim(key, source string) error {
+	if existing, ok := l.origins[key]; ok {
+		return fmt.Errorf("%s is defined in both %s and %s", key, existing, source)
+	}
+
+	l.origins[key] = source
+	return nil
+}
+
+func parseConfig(raw []byte, vars map[string]string, source string) (*Config, error) {
+	expanded, err := Expand(raw, vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand %s: %s", source, err)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(expanded, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", source, err)
+	}
+
+	return config, nil
+}
+
+func splitS3URL(url string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(url, s3Scheme), "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
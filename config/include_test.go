@@ -0,0 +1,149 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_loader_load(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    *Config
+		wantErr string
+	}{
+		{
+			name: "include",
+			path: "testdata/include/root.yml",
+			want: &Config{
+				Region:  "us-east-1",
+				Include: []string{"services/*.yml", "services/service1.yml"},
+				Services: map[string]*ServiceConfig{
+					"service1": {
+						Region:      "us-east-1",
+						ListenerARN: "listener-rule-arn-service1",
+						Canary: &PoolConfig{
+							TargetGroupARN: "tg-arn-canary-service1",
+							Service:        "service1-canary",
+						},
+						Primary: &PoolConfig{
+							TargetGroupARN: "tg-arn-primary-service1",
+							Service:        "service1",
+						},
+					},
+					"service2": {
+						Region:      "us-east-1",
+						ListenerARN: "listener-rule-arn-service2",
+					},
+				},
+				Workflows: WorkflowConfig{
+					"default": {
+						Steps: []*Action{
+							{
+								Type:   UpdatePool,
+								Target: "canary",
+								Count:  1,
+							},
+						},
+					},
+					"service2-release": {
+						Steps: []*Action{
+							{
+								Type:   TrafficShift,
+								Target: "primary",
+								Ratio:  100,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "conflict",
+			path:    "testdata/conflict/root.yml",
+			wantErr: "services.service1 is defined in both testdata/conflict/root.yml and testdata/conflict/fragment.yml",
+		},
+		{
+			name:    "missing",
+			path:    "testdata/missing.yml",
+			wantErr: "open testdata/missing.yml: no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newLoader(nil).load(tt.path)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if tt.wantErr != "" {
+				t.Fatalf("load() error = nil, wantErr %v", tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loader_resolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		parent  string
+		pattern string
+		want    []string
+	}{
+		{
+			name:    "s3_relative_key",
+			parent:  "s3://bucket/config.yml",
+			pattern: "teams/service1.yml",
+			want:    []string{"s3://bucket/teams/service1.yml"},
+		},
+		{
+			name:    "s3_relative_to_parent",
+			parent:  "s3://bucket/envs/prod/config.yml",
+			pattern: "../shared/service1.yml",
+			want:    []string{"s3://bucket/envs/shared/service1.yml"},
+		},
+		{
+			name:    "s3_bucket_root",
+			parent:  "s3://bucket/envs/prod/config.yml",
+			pattern: "/teams/service1.yml",
+			want:    []string{"s3://bucket/teams/service1.yml"},
+		},
+		{
+			name:    "s3_url",
+			parent:  "testdata/include/root.yml",
+			pattern: "s3://other/service1.yml",
+			want:    []string{"s3://other/service1.yml"},
+		},
+		{
+			name:    "local_glob",
+			parent:  "testdata/include/root.yml",
+			pattern: "services/*.yml",
+			want:    []string{"testdata/include/services/service1.yml", "testdata/include/services/service2.yml"},
+		},
+		{
+			name:    "local_glob_no_match",
+			parent:  "testdata/include/root.yml",
+			pattern: "teams/*.yml",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newLoader(nil).resolve(tt.parent, tt.pattern)
+			if err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
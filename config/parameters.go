@@ -0,0 +1,266 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// ParameterType the kind of value a workflow parameter accepts
+	ParameterType string
+
+	// Parameter a value supplied to a workflow when it is run
+	Parameter struct {
+		Type        ParameterType `yaml:"type"`
+		Default     string        `yaml:"default"`
+		Required    bool          `yaml:"required"`
+		Description string        `yaml:"description"`
+	}
+
+	// WorkflowDefinition the steps of a workflow and the parameters they reference as ${param.name}
+	WorkflowDefinition struct {
+		Parameters     map[string]*Parameter `yaml:"parameters"`
+		RollbackPolicy RollbackPolicy        `yaml:"rollback-policy"`
+		Steps          []*Action             `yaml:"steps"`
+	}
+
+	// plainAction decodes an action without looking for parameter references
+	plainAction Action
+)
+
+const (
+	StringParameter   ParameterType = "string"
+	IntParameter      ParameterType = "int"
+	DurationParameter ParameterType = "duration"
+	BoolParameter     ParameterType = "bool"
+)
+
+var (
+	// parameterPattern matches ${param.name}, which is left alone by environment expansion
+	parameterPattern = regexp.MustCompile(`\$\{param\.([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// UnmarshalYAML accepts either a plain list of steps or a mapping of parameters and steps
+func (w *WorkflowDefinition) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	if _, ok := raw.([]interface{}); ok {
+		return unmarshal(&w.Steps)
+	}
+
+	type plain WorkflowDefinition
+	return unmarshal((*plain)(w))
+}
+
+// UnmarshalYAML sets aside fields that reference parameters so they can be decoded once the parameters are bound
+func (a *Action) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := map[string]interface{}{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	for key, value := range raw {
+		if hasParameterRef(value) {
+			if a.refs == nil {
+				a.refs = map[string]interface{}{}
+			}
+			a.refs[key] = value
+			delete(raw, key)
+		}
+	}
+
+	if len(a.refs) == 0 {
+		return unmarshal((*plainAction)(a))
+	}
+
+	remaining, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(remaining, (*plainAction)(a))
+}
+
+// Bind validates params against the declared parameters, applying defaults, and returns the steps with every
+// reference replaced along with the values that were bound
+func (w *WorkflowDefinition) Bind(params map[string]string) ([]*Action, map[string]string, error) {
+	values := map[string]string{}
+	for name := range params {
+		if _, ok := w.Parameters[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+
+	names := []string{}
+	for name := range w.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		parameter := w.Parameters[name]
+		value, ok := params[name]
+		if !ok {
+			if parameter.Required {
+				return nil, nil, fmt.Errorf("missing required parameter: %s", name)
+			}
+			value = parameter.Default
+		}
+
+		if parameter.Required && strings.TrimSpace(value) == "" {
+			return nil, nil, fmt.Errorf("required parameter %s is empty", name)
+		}
+
+		if value != "" {
+			if _, err := parameter.parse(value); err != nil {
+				return nil, nil, fmt.Errorf("invalid value for parameter %s: %s", name, err)
+			}
+		}
+		values[name] = value
+	}
+
+	steps := []*Action{}
+	for i, action := range w.Steps {
+		bound, err := action.bind(w.Parameters, values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %d: %s", i+1, err)
+		}
+		steps = append(steps, bound)
+	}
+
+	return steps, values, nil
+}
+
+// bind returns a copy of the action with its parameter references decoded
+func (a *Action) bind(parameters map[string]*Parameter, values map[string]string) (*Action, error) {
+	bound := *a
+	bound.refs = nil
+
+	if len(a.OnFailure) > 0 {
+		bound.OnFailure = []*Action{}
+		for _, handler := range a.OnFailure {
+			if handler.Type == Pause || handler.Type == TakeCheckpoint {
+				return nil, fmt.Errorf("on-failure: %s steps are not allowed", handler.Type)
+			}
+
+			boundHandler, err := handler.bind(parameters, values)
+			if err != nil {
+				return nil, fmt.Errorf("on-failure: %s", err)
+			}
+			bound.OnFailure = append(bound.OnFailure, boundHandler)
+		}
+	}
+
+	if len(a.refs) == 0 {
+		return &bound, nil
+	}
+
+	raw := map[string]interface{}{}
+	for key, value := range a.refs {
+		substituted, err := substitute(value, parameters, values)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s", key, err)
+		}
+
+		if substituted != nil {
+			raw[key] = substituted
+		}
+	}
+
+	encoded, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(encoded, (*plainAction)(&bound)); err != nil {
+		return nil, err
+	}
+
+	if _, ok := a.refs["action"]; ok {
+		if err := checkActionType(bound.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	return &bound, nil
+}
+
+// substitute replaces the parameter references of a string or list. A string that is a single reference takes
+// the typed value of the parameter, or nil when the parameter is unset
+func substitute(value interface{}, parameters map[string]*Parameter, values map[string]string) (interface{}, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		items := []interface{}{}
+		for _, item := range value {
+			substituted, err := substitute(item, parameters, values)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, substituted)
+		}
+		return items, nil
+
+	case string:
+		for _, groups := range parameterPattern.FindAllStringSubmatch(value, -1) {
+			if _, ok := values[groups[1]]; !ok {
+				return nil, fmt.Errorf("references undeclared parameter %s", groups[1])
+			}
+		}
+
+		if groups := parameterPattern.FindStringSubmatch(value); groups != nil && groups[0] == strings.TrimSpace(value) {
+			if values[groups[1]] == "" {
+				return nil, nil
+			}
+			return parameters[groups[1]].parse(values[groups[1]])
+		}
+
+		return parameterPattern.ReplaceAllStringFunc(value, func(match string) string {
+			return values[parameterPattern.FindStringSubmatch(match)[1]]
+		}), nil
+	}
+
+	return value, nil
+}
+
+func hasParameterRef(value interface{}) bool {
+	switch value := value.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if hasParameterRef(item) {
+				return true
+			}
+		}
+	case string:
+		return parameterPattern.MatchString(value)
+	}
+
+	return false
+}
+
+// parse checks value against the parameters type returning it as the matching yaml value
+func (p *Parameter) parse(value string) (interface{}, error) {
+	switch p.Type {
+	case IntParameter:
+		return strconv.ParseInt(value, 10, 64)
+	case BoolParameter:
+		return strconv.ParseBool(value)
+	case DurationParameter:
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case StringParameter, "":
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("unknown parameter type %s", p.Type)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWorkflowDefinition_Bind(t *testing.T) {
+	config := NewConfigFromFile("testdata/parameters.yml", nil)
+	if config == nil {
+		t.Fatal("NewConfigFromFile() = nil")
+	}
+	definition := config.Workflows["canary"]
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		want       []*Action
+		wantValues map[string]string
+		wantErr    bool
+	}{
+		{
+			name:   "defaults",
+			params: map[string]string{"bakeTime": "5m"},
+			want: []*Action{
+				{Type: UpdatePool, Target: "canary", Count: 1},
+				{Type: ValidatePool, Target: "task", Command: []string{"smoke", "--target=canary", "1"}},
+				{Type: Wait, Duration: 5 * time.Minute},
+				{Type: TrafficShift, Target: "canary", Ratio: 10},
+			},
+			wantValues: map[string]string{"bakeTime": "5m", "canaryCount": "1", "smokeTarget": "canary"},
+		},
+		{
+			name:   "supplied",
+			params: map[string]string{"bakeTime": "90s", "canaryCount": "3", "smokeTarget": "blue"},
+			want: []*Action{
+				{Type: UpdatePool, Target: "canary", Count: 3},
+				{Type: ValidatePool, Target: "task", Command: []string{"smoke", "--target=blue", "3"}},
+				{Type: Wait, Duration: 90 * time.Second},
+				{Type: TrafficShift, Target: "canary", Ratio: 10},
+			},
+			wantValues: map[string]string{"bakeTime": "90s", "canaryCount": "3", "smokeTarget": "blue"},
+		},
+		{
+			name:    "missing_required",
+			params:  map[string]string{},
+			wantErr: true,
+		},
+		{
+			name:    "empty_required",
+			params:  map[string]string{"bakeTime": ""},
+			wantErr: true,
+		},
+		{
+			name:    "wrong_type",
+			params:  map[string]string{"bakeTime": "5m", "canaryCount": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown",
+			params:  map[string]string{"bakeTime": "5m", "region": "eu-west-1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, values, err := definition.Bind(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Bind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Bind() got = %+v, want %+v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("Bind() values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestWorkflowDefinition_Bind_undeclared(t *testing.T) {
+	definition := &WorkflowDefinition{
+		Steps: []*Action{{Type: UpdatePool, refs: map[string]interface{}{"count": "${param.canaryCount}"}}},
+	}
+
+	if _, _, err := definition.Bind(nil); err == nil {
+		t.Error("Bind() error = nil, want undeclared parameter error")
+	}
+}
+
+func TestConfig_GetWorkflowDefinition(t *testing.T) {
+	config := NewConfigFromFile("testdata/parameters.yml", nil)
+	if config == nil {
+		t.Fatal("NewConfigFromFile() = nil")
+	}
+
+	if got := config.GetWorkflowDefinition("service1", config.GetWorkflow("service1")); len(got.Steps) != 1 || got.Steps[0].Count != 2 {
+		t.Errorf("GetWorkflowDefinition() = %+v, want the service override", got)
+	}
+
+	config.Services["service2"] = &ServiceConfig{}
+	if got := config.GetWorkflowDefinition("service2", "canary"); got != config.Workflows["canary"] {
+		t.Errorf("GetWorkflowDefinition() = %+v, want the shared workflow", got)
+	}
+}
+
+func TestWorkflowDefinition_Bind_failureHandling(t *testing.T) {
+	config := NewConfigFromFile("testdata/parameters.yml", nil)
+	if config == nil {
+		t.Fatal("NewConfigFromFile() = nil")
+	}
+
+	definition := config.GetWorkflowDefinition("service2", "guarded")
+	if definition.RollbackPolicy != RollbackToStart {
+		t.Errorf("RollbackPolicy = %s, want %s", definition.RollbackPolicy, RollbackToStart)
+	}
+
+	got, _, err := definition.Bind(nil)
+	if err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+
+	noRollback := false
+	want := []*Action{
+		{
+			Type:       ValidatePool,
+			Target:     "task",
+			Retries:    2,
+			RetryDelay: 30 * time.Second,
+			When:       "canary-weight > 0",
+			Rollback:   &noRollback,
+			OnFailure: []*Action{
+				{Type: ValidatePool, Target: "task", Command: []string{"page-oncall"}},
+				{Type: TrafficShift, Target: "primary", Ratio: 100},
+			},
+		},
+		{Type: UpdatePool, Target: "primary", ContinueOnFailure: true},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Bind() = %+v, want %+v", got, want)
+	}
+
+	if got[0].RollbackOnFailure() || !got[1].RollbackOnFailure() {
+		t.Error("RollbackOnFailure() did not follow the rollback setting")
+	}
+}
+
+func TestWorkflowDefinition_Bind_onFailureSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler ActionType
+		wantErr bool
+	}{
+		{name: "shift", handler: TrafficShift},
+		{name: "pause", handler: Pause, wantErr: true},
+		{name: "checkpoint", handler: TakeCheckpoint, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition := &WorkflowDefinition{
+				Steps: []*Action{{Type: UpdatePool, Target: "canary", OnFailure: []*Action{{Type: tt.handler}}}},
+			}
+
+			if _, _, err := definition.Bind(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Bind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
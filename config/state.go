@@ -2,8 +2,8 @@ package config
 
 type (
 	ServiceState struct {
-		Count   int64
-		TaskDef string
+		Count   int64  `json:"count"`
+		TaskDef string `json:"taskDef"`
 	}
 
 	TaskState struct {
@@ -11,9 +11,41 @@ type (
 		Failed  bool
 	}
 
+	// TargetHealth summary of a target groups registered targets
+	TargetHealth struct {
+		Healthy int64
+		Total   int64
+	}
+
+	// ScalingState the application auto scaling settings of a pool
+	ScalingState struct {
+		MinCapacity                int64
+		MaxCapacity                int64
+		DynamicScalingInSuspended  bool
+		DynamicScalingOutSuspended bool
+		ScheduledScalingSuspended  bool
+	}
+
 	// ServiceWeights the listener/target group weights
 	ServiceWeights struct {
-		Canary  int64
-		Primary int64
+		Canary  int64 `json:"canary"`
+		Primary int64 `json:"primary"`
 	}
 )
+
+// IsHealthy tests whether the target health satisfies the health check thresholds
+func (h *HealthCheck) IsHealthy(health *TargetHealth) bool {
+	if health == nil || health.Healthy < h.MinHealthy {
+		return false
+	}
+
+	if h.MinHealthyFraction > 0 {
+		if health.Total == 0 {
+			return false
+		}
+
+		return float64(health.Healthy)/float64(health.Total) >= h.MinHealthyFraction
+	}
+
+	return true
+}
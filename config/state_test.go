@@ -0,0 +1,61 @@
+package config
+
+import "testing"
+
+func TestHealthCheck_IsHealthy(t *testing.T) {
+	type args struct {
+		health *TargetHealth
+	}
+	tests := []struct {
+		name  string
+		check *HealthCheck
+		args  args
+		want  bool
+	}{
+		{
+			name:  "no_targets",
+			check: &HealthCheck{MinHealthy: 1},
+			args: args{
+				health: &TargetHealth{},
+			},
+			want: false,
+		},
+		{
+			name:  "min_healthy_met",
+			check: &HealthCheck{MinHealthy: 2},
+			args: args{
+				health: &TargetHealth{Healthy: 2, Total: 4},
+			},
+			want: true,
+		},
+		{
+			name:  "fraction_not_met",
+			check: &HealthCheck{MinHealthy: 1, MinHealthyFraction: 0.75},
+			args: args{
+				health: &TargetHealth{Healthy: 2, Total: 4},
+			},
+			want: false,
+		},
+		{
+			name:  "fraction_met",
+			check: &HealthCheck{MinHealthyFraction: 0.5},
+			args: args{
+				health: &TargetHealth{Healthy: 2, Total: 4},
+			},
+			want: true,
+		},
+		{
+			name:  "nil_health",
+			check: &HealthCheck{},
+			args:  args{},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.check.IsHealthy(tt.args.health); got != tt.want {
+				t.Errorf("HealthCheck.IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
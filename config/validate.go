@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// actionTypes every action a workflow step may perform
+var actionTypes = []ActionType{TrafficShift, ValidatePool, UpdatePool, Wait, TakeCheckpoint, Pause}
+
+// validate rejects settings that would otherwise only fail once a workflow runs
+func (c *Config) validate() error {
+	if err := validateWorkflows("workflows", c.Workflows); err != nil {
+		return err
+	}
+
+	for _, name := range sortedServices(c.Services) {
+		if c.Services[name] == nil {
+			continue
+		}
+
+		if err := validateWorkflows(fmt.Sprintf("services.%s.workflows", name), c.Services[name].Workflows); err != nil {
+			return err
+		}
+
+		if err := c.Services[name].Approval.validate(); err != nil {
+			return fmt.Errorf("services.%s.approval: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks a gate with listed approvers can be satisfied, each approver has a single vote
+func (a *ApprovalConfig) validate() error {
+	if a == nil || len(a.Approvers) == 0 {
+		return nil
+	}
+
+	approvers := map[string]bool{}
+	for _, approver := range a.Approvers {
+		approvers[approver] = true
+	}
+
+	if a.Required > len(approvers) {
+		return fmt.Errorf("required-approvals is %d but only %d approvers are listed", a.Required, len(approvers))
+	}
+
+	return nil
+}
+
+func validateWorkflows(path string, workflows WorkflowConfig) error {
+	names := []string{}
+	for name := range workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if workflows[name] == nil {
+			continue
+		}
+
+		for i, step := range workflows[name].Steps {
+			if err := step.validate(); err != nil {
+				return fmt.Errorf("%s.%s step %d: %s", path, name, i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate checks the action of the step and its on-failure steps, an action set by a parameter is checked once bound
+func (a *Action) validate() error {
+	if _, ok := a.refs["action"]; !ok {
+		if err := checkActionType(a.Type); err != nil {
+			return err
+		}
+	}
+
+	for _, handler := range a.OnFailure {
+		if err := handler.validate(); err != nil {
+			return fmt.Errorf("on-failure: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func checkActionType(actionType ActionType) error {
+	valid := []string{}
+	for _, known := range actionTypes {
+		if actionType == known {
+			return nil
+		}
+		valid = append(valid, string(known))
+	}
+
+	return fmt.Errorf("unknown action %q, valid actions: %s", actionType, strings.Join(valid, ", "))
+}
+
+func sortedServices(services map[string]*ServiceConfig) []string {
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			raw: `workflows:
+  default:
+    steps:
+      - action: update
+        target: canary
+        on-failure:
+          - action: shift
+            target: primary
+            ratio: 100
+      - action: checkpoint
+      - action: pause
+`,
+		},
+		{
+			name: "unknown_action",
+			raw: `workflows:
+  default:
+    steps:
+      - action: update
+      - action: traffic-shift
+`,
+			wantErr: `workflows.default step 2: unknown action "traffic-shift", valid actions: shift, validate, update, wait, checkpoint, pause`,
+		},
+		{
+			name: "missing_action",
+			raw: `workflows:
+  default:
+    steps:
+      - target: canary
+`,
+			wantErr: `workflows.default step 1: unknown action ""`,
+		},
+		{
+			name: "unknown_on_failure_action",
+			raw: `workflows:
+  default:
+    steps:
+      - action: validate
+        on-failure:
+          - action: notify
+`,
+			wantErr: `workflows.default step 1: on-failure: unknown action "notify"`,
+		},
+		{
+			name: "unknown_service_workflow_action",
+			raw: `services:
+  service1:
+    workflows:
+      canary:
+        steps:
+          - action: shfit
+`,
+			wantErr: `services.service1.workflows.canary step 1: unknown action "shfit"`,
+		},
+		{
+			name: "approvals_satisfiable",
+			raw: `services:
+  service1:
+    approval:
+      approvers: [alice, bob]
+      required-approvals: 2
+  service2:
+    approval:
+      required-approvals: 3
+`,
+		},
+		{
+			name: "approvals_unsatisfiable",
+			raw: `services:
+  service1:
+    approval:
+      approvers: [alice, bob, alice]
+      required-approvals: 3
+`,
+			wantErr: "services.service1.approval: required-approvals is 3 but only 2 approvers are listed",
+		},
+		{
+			name: "default_approvals_unsatisfiable",
+			raw: `defaults:
+  approval:
+    required-approvals: 2
+services:
+  service1:
+    approval:
+      approvers: [alice]
+`,
+			wantErr: "services.service1.approval: required-approvals is 2 but only 1 approvers are listed",
+		},
+		{
+			name: "parameter_action",
+			raw: `workflows:
+  default:
+    parameters:
+      first:
+        type: string
+        default: update
+    steps:
+      - action: ${param.first}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseConfig([]byte(tt.raw), nil, tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.applyDefaults()
+
+			err = config.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkflowDefinition_Bind_unknownAction(t *testing.T) {
+	config, err := parseConfig([]byte(`workflows:
+  default:
+    parameters:
+      first:
+        type: string
+        default: update
+    steps:
+      - action: ${param.first}
+`), nil, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition := config.Workflows["default"]
+
+	if steps, _, err := definition.Bind(nil); err != nil || steps[0].Type != UpdatePool {
+		t.Errorf("Bind() = %+v, %v, want an update step", steps, err)
+	}
+
+	if _, _, err := definition.Bind(map[string]string{"first": "upgrade"}); err == nil || !strings.Contains(err.Error(), `unknown action "upgrade"`) {
+		t.Errorf("Bind() error = %v, want unknown action", err)
+	}
+}
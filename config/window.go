@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// DeployWindow a recurring period deployments are allowed in, either days and a time of day range or a cron
+	// expression matching every minute of the window. Times are in Timezone, UTC when unset
+	DeployWindow struct {
+		// Days such as mon or mon-fri, every day when unset
+		Days []string `yaml:"days"`
+		// Start and End times of day as 15:04, an end before the start closes the window the following day
+		Start    string `yaml:"start"`
+		End      string `yaml:"end"`
+		Cron     string `yaml:"cron"`
+		Timezone string `yaml:"timezone"`
+	}
+
+	// Freeze a period no deployments are allowed in. Start and End are dates or RFC 3339 times in Timezone, UTC when
+	// unset, and an end date freezes the whole of that day
+	Freeze struct {
+		Name     string `yaml:"name"`
+		Start    string `yaml:"start"`
+		End      string `yaml:"end"`
+		Timezone string `yaml:"timezone"`
+	}
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timeOfDayLayout = "15:04"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// CheckDeployWindow returns why the service may not be deployed at now, a freeze in effect or every deploy window
+// being closed, or nil when it may
+func (c *Config) CheckDeployWindow(service string, now time.Time) error {
+	serviceConfig := c.Services[service]
+	if serviceConfig == nil {
+		return nil
+	}
+
+	for _, freeze := range serviceConfig.Freezes {
+		end, active, err := freeze.active(now)
+		if err != nil {
+			return fmt.Errorf("invalid freeze %s: %s", freeze.Name, err)
+		}
+
+		if active {
+			return fmt.Errorf("%s is frozen until %s: %s", service, end.Format(time.RFC3339), freeze.Name)
+		}
+	}
+
+	if len(serviceConfig.DeployWindows) == 0 {
+		return nil
+	}
+
+	for i, window := range serviceConfig.DeployWindows {
+		open, err := window.Open(now)
+		if err != nil {
+			return fmt.Errorf("invalid deploy window %d: %s", i+1, err)
+		}
+
+		if open {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is outside of its deploy windows", service)
+}
+
+// Open whether the window allows deployments at now
+func (w *DeployWindow) Open(now time.Time) (bool, error) {
+	location, err := loadLocation(w.Timezone)
+	if err != nil {
+		return false, err
+	}
+	now = now.In(location)
+
+	if w.Cron != "" {
+		return matchCron(w.Cron, now)
+	}
+
+	days, err := parseDays(w.Days)
+	if err != nil {
+		return false, err
+	}
+
+	start, end := 0, 24*60
+	if w.Start != "" {
+		if start, err = minuteOfDay(w.Start); err != nil {
+			return false, err
+		}
+	}
+	if w.End != "" {
+		if end, err = minuteOfDay(w.End); err != nil {
+			return false, err
+		}
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return days[now.Weekday()] && minute >= start && minute < end, nil
+	}
+
+	// the window runs past midnight, the early hours belong to the window opened the day before
+	if minute >= start {
+		return days[now.Weekday()], nil
+	}
+
+	return minute < end && days[(now.Weekday()+6)%7], nil
+}
+
+// active returns the end of the freeze and whether now falls within it
+func (f *Freeze) active(now time.Time) (time.Time, bool, error) {
+	location, err := loadLocation(f.Timezone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	start, err := parseFreezeTime(f.Start, location, false)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	end, err := parseFreezeTime(f.End, location, true)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return end, !now.Before(start) && now.Before(end), nil
+}
+
+// parseFreezeTime parses a date or RFC 3339 time, a date as the end of a freeze is the start of the following day
+func parseFreezeTime(value string, location *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(dateLayout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date or RFC 3339 time: %s", value)
+	}
+
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+func loadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(timezone)
+}
+
+func minuteOfDay(value string) (int, error) {
+	t, err := time.Parse(timeOfDayLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a time of day such as 09:30: %s", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseDays returns the weekdays named by days, every day when there are none
+func parseDays(days []string) (map[time.Weekday]bool, error) {
+	result := map[time.Weekday]bool{}
+	if len(days) == 0 {
+		for _, day := range weekdays {
+			result[day] = true
+		}
+		return result, nil
+	}
+
+	for _, value := range days {
+		bounds := strings.SplitN(strings.ToLower(value), "-", 2)
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown day: %s", value)
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return nil, fmt.Errorf("unknown day: %s", value)
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			result[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// matchCron reports whether now matches a five field cron expression: minute hour day-of-month month day-of-week.
+// As with cron a restricted day of month and day of week match when either does
+func matchCron(expression string, now time.Time) (bool, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return false, fmt.Errorf("expected five fields in cron expression: %s", expression)
+	}
+
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	values := []int{now.Minute(), now.Hour(), now.Day(), int(now.Month()), int(now.Weekday())}
+
+	matches := make([]bool, len(fields))
+	for i, field := range fields {
+		allowed, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return false, fmt.Errorf("invalid cron field %s: %s", field, err)
+		}
+
+		// 7 is sunday as well as 0
+		matches[i] = allowed[values[i]] || (i == 4 && values[i] == 0 && allowed[7])
+	}
+
+	day := matches[2] && matches[4]
+	if fields[2] != "*" && fields[4] != "*" {
+		day = matches[2] || matches[4]
+	}
+
+	return matches[0] && matches[1] && matches[3] && day, nil
+}
+
+// parseCronField returns the values allowed by a comma separated list of *, n, n-m, each optionally with a /step
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	allowed := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step: %s", part)
+			}
+			part = part[:i]
+		}
+
+		first, last := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if first, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value: %s", part)
+			}
+
+			last = first
+			if len(bounds) == 2 {
+				if last, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value: %s", part)
+				}
+			} else if step > 1 {
+				last = max
+			}
+		}
+
+		if first < min || last > max || first > last {
+			return nil, fmt.Errorf("out of range %d-%d: %s", min, max, part)
+		}
+
+		for value := first; value <= last; value += step {
+			allowed[value] = true
+		}
+	}
+
+	return allowed, nil
+}
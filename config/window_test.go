@@ -0,0 +1,172 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeployWindow_Open(t *testing.T) {
+	// a wednesday
+	wednesday := time.Date(2020, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		window  *DeployWindow
+		now     time.Time
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "weekday_hours",
+			window: &DeployWindow{Days: []string{"mon-fri"}, Start: "09:00", End: "17:00"},
+			now:    wednesday.Add(10 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "after_hours",
+			window: &DeployWindow{Days: []string{"mon-fri"}, Start: "09:00", End: "17:00"},
+			now:    wednesday.Add(17 * time.Hour),
+			want:   false,
+		},
+		{
+			name:   "weekend",
+			window: &DeployWindow{Days: []string{"mon-fri"}, Start: "09:00", End: "17:00"},
+			now:    wednesday.AddDate(0, 0, 3).Add(10 * time.Hour),
+			want:   false,
+		},
+		{
+			name:   "wrapping_day_range",
+			window: &DeployWindow{Days: []string{"sat-sun"}},
+			now:    wednesday.AddDate(0, 0, 4).Add(10 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "past_midnight",
+			window: &DeployWindow{Days: []string{"tue"}, Start: "22:00", End: "02:00"},
+			now:    wednesday.Add(time.Hour),
+			want:   true,
+		},
+		{
+			name:   "past_midnight_wrong_day",
+			window: &DeployWindow{Days: []string{"wed"}, Start: "22:00", End: "02:00"},
+			now:    wednesday.Add(time.Hour),
+			want:   false,
+		},
+		{
+			name:   "timezone",
+			window: &DeployWindow{Days: []string{"wed"}, Start: "09:00", End: "17:00", Timezone: "America/New_York"},
+			now:    wednesday.Add(14 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "cron",
+			window: &DeployWindow{Cron: "* 9-16 * * 1-5"},
+			now:    wednesday.Add(16*time.Hour + 59*time.Minute),
+			want:   true,
+		},
+		{
+			name:   "cron_closed",
+			window: &DeployWindow{Cron: "* 9-16 * * 1-5"},
+			now:    wednesday.Add(17 * time.Hour),
+			want:   false,
+		},
+		{
+			name:   "cron_sunday_as_7",
+			window: &DeployWindow{Cron: "0-30/10 * * * 7"},
+			now:    wednesday.AddDate(0, 0, 4).Add(20 * time.Minute),
+			want:   true,
+		},
+		{
+			name:   "cron_day_of_month_or_week",
+			window: &DeployWindow{Cron: "* * 5 * 0"},
+			now:    wednesday,
+			want:   true,
+		},
+		{
+			name:    "unknown_day",
+			window:  &DeployWindow{Days: []string{"someday"}},
+			now:     wednesday,
+			wantErr: true,
+		},
+		{
+			name:    "invalid_cron",
+			window:  &DeployWindow{Cron: "* 25 * * *"},
+			now:     wednesday,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.window.Open(tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeployWindow.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("DeployWindow.Open() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_CheckDeployWindow(t *testing.T) {
+	friday := time.Date(2020, 12, 25, 12, 0, 0, 0, time.UTC)
+	holidays := &Freeze{Name: "holidays", Start: "2020-12-24", End: "2020-12-26"}
+	weekdays := &DeployWindow{Days: []string{"mon-fri"}}
+
+	tests := []struct {
+		name    string
+		service *ServiceConfig
+		now     time.Time
+		wantErr bool
+	}{
+		{
+			name:    "unrestricted",
+			service: &ServiceConfig{},
+			now:     friday,
+		},
+		{
+			name:    "frozen",
+			service: &ServiceConfig{Freezes: []*Freeze{holidays}, DeployWindows: []*DeployWindow{weekdays}},
+			now:     friday,
+			wantErr: true,
+		},
+		{
+			name:    "last_day_of_freeze",
+			service: &ServiceConfig{Freezes: []*Freeze{holidays}},
+			now:     friday.AddDate(0, 0, 1).Add(11 * time.Hour),
+			wantErr: true,
+		},
+		{
+			name:    "after_freeze",
+			service: &ServiceConfig{Freezes: []*Freeze{holidays}, DeployWindows: []*DeployWindow{weekdays}},
+			now:     friday.AddDate(0, 0, 3),
+		},
+		{
+			name:    "outside_windows",
+			service: &ServiceConfig{DeployWindows: []*DeployWindow{weekdays}},
+			now:     friday.AddDate(0, 0, 1),
+			wantErr: true,
+		},
+		{
+			name:    "rfc3339_freeze",
+			service: &ServiceConfig{Freezes: []*Freeze{{Start: "2020-12-25T11:00:00Z", End: "2020-12-25T13:00:00Z"}}},
+			now:     friday,
+			wantErr: true,
+		},
+		{
+			name:    "invalid_freeze",
+			service: &ServiceConfig{Freezes: []*Freeze{{Start: "christmas", End: "2020-12-26"}}},
+			now:     friday,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Services: map[string]*ServiceConfig{"service1": tt.service}}
+			if err := c.CheckDeployWindow("service1", tt.now); (err != nil) != tt.wantErr {
+				t.Errorf("Config.CheckDeployWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
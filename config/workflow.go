@@ -1,24 +1,62 @@
 package config
 
+import "time"
+
 type (
 	ActionType string
 
+	// RollbackPolicy which checkpoint a failed workflow returns to
+	RollbackPolicy string
+
 	Action struct {
-		Type      ActionType `yaml:"action"`
-		Target    string     `yaml:"target"`
-		Ratio     int64      `yaml:"ratio"`
-		Validator string     `yaml:"validator"`
-		Count     int64      `yaml:"count"`
-		Task      string     `yaml:"task"`
-		Command   []string   `yaml:"command"`
+		// refs fields that reference workflow parameters, decoded once the parameters are bound
+		refs map[string]interface{}
+
+		Type      ActionType `yaml:"action" json:"action"`
+		Target    string     `yaml:"target" json:"target"`
+		Ratio     int64      `yaml:"ratio" json:"ratio,omitempty"`
+		Validator string     `yaml:"validator" json:"validator,omitempty"`
+		Count     int64      `yaml:"count" json:"count,omitempty"`
+		Task      string     `yaml:"task" json:"task,omitempty"`
+		Command   []string   `yaml:"command" json:"command,omitempty"`
+		PreScale  bool       `yaml:"pre-scale" json:"preScale,omitempty"`
+		ScaleDown bool       `yaml:"scale-down" json:"scaleDown,omitempty"`
+		// Duration how long a wait action pauses the workflow
+		Duration time.Duration `yaml:"duration" json:"duration,omitempty"`
+
+		Retries    int64         `yaml:"retries" json:"retries,omitempty"`
+		RetryDelay time.Duration `yaml:"retry-delay" json:"retryDelay,omitempty"`
+		// ContinueOnFailure moves on to the next step once the action has failed and its on-failure steps have run
+		ContinueOnFailure bool `yaml:"continue-on-failure" json:"continueOnFailure,omitempty"`
+		// When a condition such as "canary-weight > 0" the step only runs if it holds
+		When string `yaml:"when" json:"when,omitempty"`
+		// OnFailure steps run when the action fails, before the workflow is rolled back or continues. They may not pause
+		// or take checkpoints
+		OnFailure []*Action `yaml:"on-failure" json:"onFailure,omitempty"`
+		// Rollback whether a failure rolls back to the checkpoint, defaults to true
+		Rollback *bool `yaml:"rollback" json:"rollback,omitempty"`
+		// Hold keeps a paused workflow running until it is continued or aborted instead of exiting
+		Hold bool `yaml:"hold" json:"hold,omitempty"`
+		// AbortAfter how long a paused workflow may wait before it is aborted, it waits indefinitely when unset
+		AbortAfter time.Duration `yaml:"abort-after" json:"abortAfter,omitempty"`
 	}
 
 	Workflow struct {
 		Config  *Config
 		Service string
+		Name    string
+		// Parameters the values the steps were bound with
+		Parameters     map[string]string
+		RollbackPolicy RollbackPolicy
+		// OverrideReason why the deploy windows and freezes of the service are overridden, they are enforced when empty
+		OverrideReason string
+		// Rollback whether the workflow restores an earlier release, rollbacks ignore deploy windows and freezes
+		Rollback bool
 
-		Default *ServiceState
-		Steps   []*Action
+		// Default the state update steps deploy to either pool, PoolDefaults keyed by pool take precedence over it
+		Default      *ServiceState
+		PoolDefaults map[string]*ServiceState
+		Steps        []*Action
 	}
 )
 
@@ -26,4 +64,21 @@ const (
 	TrafficShift ActionType = "shift"
 	ValidatePool ActionType = "validate"
 	UpdatePool   ActionType = "update"
+	Wait         ActionType = "wait"
+	// TakeCheckpoint records the current weights and pool states as a point to roll back to
+	TakeCheckpoint ActionType = "checkpoint"
+	// Pause saves the run so it can be resumed with pompeii continue or rolled back with pompeii abort
+	Pause ActionType = "pause"
 )
+
+const (
+	// RollbackToLastCheckpoint returns to the most recent checkpoint step, or the start when none ran
+	RollbackToLastCheckpoint RollbackPolicy = "to-last-checkpoint"
+	// RollbackToStart returns to the state captured before the first step
+	RollbackToStart RollbackPolicy = "to-start"
+)
+
+// RollbackOnFailure whether a failure of the action rolls the workflow back to its checkpoint
+func (a *Action) RollbackOnFailure() bool {
+	return a.Rollback == nil || *a.Rollback
+}
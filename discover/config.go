@@ -0,0 +1,258 @@
+package discover
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/chriskuchin/pompeii/config"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// templatePlaceholder stands in for a template action while the config is edited
+	templatePlaceholder = "__pompeii_template_"
+)
+
+type (
+	// Drift a difference between the config and what was discovered
+	Drift struct {
+		Path       string
+		Configured string
+		Discovered string
+		Note       string
+	}
+
+	// field a config value discovered for a service, left alone when any of its alternatives is configured
+	field struct {
+		path         []string
+		value        string
+		alternatives []string
+	}
+)
+
+func (d *Drift) String() string {
+	switch {
+	case d.Note != "":
+		return fmt.Sprintf("%s: %s", d.Path, d.Note)
+	case d.Configured == "":
+		return fmt.Sprintf("%s: added %s", d.Path, d.Discovered)
+	case d.Discovered == "":
+		return fmt.Sprintf("%s: %s was not found in aws", d.Path, d.Configured)
+	}
+
+	return fmt.Sprintf("%s: %s -> %s", d.Path, d.Configured, d.Discovered)
+}
+
+// Update merges the discovered services into the services section of the raw yaml config, keeping every other
+// setting, comment and template, and returns the updated yaml along with the drift between the two. Services that
+// loaded defines in an included file are reported but not written, and neither are templated values
+func Update(raw []byte, loaded *config.Config, services []*Service) ([]byte, []*Drift, error) {
+	masked, templates := maskTemplates(raw)
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(masked, doc); err != nil {
+		return nil, nil, err
+	}
+
+	if doc.Kind == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config is not a yaml mapping")
+	}
+
+	drift := []*Drift{}
+	changed := false
+	discovered := map[string]bool{}
+	for _, service := range services {
+		discovered[service.Name] = true
+
+		if lookup(root, []string{"services", service.Name}) == nil && loaded != nil && loaded.Services[service.Name] != nil {
+			drift = append(drift, &Drift{Path: "services." + service.Name, Note: "defined in an included file, skipped"})
+			continue
+		}
+
+		for _, field := range service.fields() {
+			if hasAny(root, field.path[:len(field.path)-1], field.alternatives) {
+				continue
+			}
+
+			path := strings.Join(field.path, ".")
+			current := ""
+			if configured := lookup(root, field.path); configured != nil {
+				current = configured.Value
+				if isTemplated(configured) {
+					drift = append(drift, &Drift{Path: path, Note: fmt.Sprintf("templated, left as is (discovered %s)", field.value)})
+					continue
+				}
+			}
+
+			if current == field.value {
+				continue
+			}
+
+			drift = append(drift, &Drift{Path: path, Configured: current, Discovered: field.value})
+			set(root, field.path, field.value)
+			changed = true
+		}
+	}
+
+	if configured := lookup(root, []string{"services"}); configured != nil && configured.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(configured.Content); i += 2 {
+			name := configured.Content[i].Value
+			if !discovered[name] {
+				drift = append(drift, &Drift{Path: "services." + name, Note: "no resources are tagged for this service"})
+			}
+		}
+	}
+
+	// an up to date config is left byte for byte as it is
+	if !changed {
+		return raw, drift, nil
+	}
+
+	out := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return unmaskTemplates(out.Bytes(), templates), drift, nil
+}
+
+func (s *Service) fields() []*field {
+	fields := []*field{}
+	add := func(value string, alternatives []string, path ...string) {
+		if value != "" {
+			fields = append(fields, &field{path: append([]string{"services", s.Name}, path...), value: value, alternatives: alternatives})
+		}
+	}
+
+	add(s.ClusterARN, []string{"cluster"}, "cluster-arn")
+	add(s.ListenerARN, []string{"listener-rule"}, "listener-rule-arn")
+	if s.Canary != nil {
+		add(s.Canary.ECSService, nil, "canary", "ecs-service")
+		add(s.Canary.TargetGroupARN, []string{"tg-name", "tg-tags"}, "canary", "tg-arn")
+	}
+
+	if s.Primary != nil {
+		add(s.Primary.ECSService, nil, "primary", "ecs-service")
+		add(s.Primary.TargetGroupARN, []string{"tg-name", "tg-tags"}, "primary", "tg-arn")
+	}
+
+	return fields
+}
+
+// lookup returns the value node at path, or nil when it isn't configured
+func lookup(node *yaml.Node, path []string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return node.Content[i+1]
+		}
+
+		return lookup(node.Content[i+1], path[1:])
+	}
+
+	return nil
+}
+
+// set writes value at path, creating the missing mappings. An existing scalar keeps its style and comments
+func set(node *yaml.Node, path []string, value string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+
+		child := node.Content[i+1]
+		if len(path) == 1 {
+			if child.Kind != yaml.ScalarNode {
+				*child = yaml.Node{Kind: yaml.ScalarNode}
+			}
+			child.Tag = "!!str"
+			child.Value = value
+			return
+		}
+
+		if child.Kind != yaml.MappingNode {
+			*child = yaml.Node{Kind: yaml.MappingNode, HeadComment: child.HeadComment, LineComment: child.LineComment}
+		}
+		set(child, path[1:], value)
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		node.Content = append(node.Content, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+		return
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, key, child)
+	set(child, path[1:], value)
+}
+
+func hasAny(node *yaml.Node, path []string, keys []string) bool {
+	for _, key := range keys {
+		if lookup(node, append(append([]string{}, path...), key)) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTemplated reports whether a configured value is rendered from a template or the environment when loaded
+func isTemplated(node *yaml.Node) bool {
+	return node.Kind != yaml.ScalarNode || strings.Contains(node.Value, templatePlaceholder) || strings.Contains(node.Value, "${")
+}
+
+// maskTemplates replaces each [[ ]] template action with a plain placeholder, so the config parses as the yaml it
+// renders to and the actions are written back exactly as they were
+func maskTemplates(raw []byte) ([]byte, []string) {
+	templates := []string{}
+	masked := &bytes.Buffer{}
+	rest := string(raw)
+	for {
+		start := strings.Index(rest, "[[")
+		if start < 0 {
+			break
+		}
+
+		end := strings.Index(rest[start+2:], "]]")
+		if end < 0 {
+			break
+		}
+		end += start + 4
+
+		masked.WriteString(rest[:start])
+		fmt.Fprintf(masked, "%s%d__", templatePlaceholder, len(templates))
+		templates = append(templates, rest[start:end])
+		rest = rest[end:]
+	}
+	masked.WriteString(rest)
+
+	return masked.Bytes(), templates
+}
+
+func unmaskTemplates(masked []byte, templates []string) []byte {
+	out := string(masked)
+	for i, action := range templates {
+		out = strings.Replace(out, fmt.Sprintf("%s%d__", templatePlaceholder, i), action, 1)
+	}
+
+	return []byte(out)
+}
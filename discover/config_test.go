@@ -0,0 +1,171 @@
+package discover
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+func TestUpdate(t *testing.T) {
+	discovered := []*Service{
+		{
+			Name:        "service1",
+			ClusterARN:  "cluster-arn",
+			ListenerARN: "rule-arn",
+			Canary:      &Pool{ECSService: "service1-canary", TargetGroupARN: "tg-canary"},
+			Primary:     &Pool{ECSService: "service1", TargetGroupARN: "tg-primary"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		raw       string
+		loaded    *config.Config
+		services  []*Service
+		want      string
+		wantDrift []string
+	}{
+		{
+			name:     "generate",
+			raw:      "",
+			services: discovered,
+			want: `services:
+  service1:
+    cluster-arn: cluster-arn
+    listener-rule-arn: rule-arn
+    canary:
+      ecs-service: service1-canary
+      tg-arn: tg-canary
+    primary:
+      ecs-service: service1
+      tg-arn: tg-primary
+`,
+			wantDrift: []string{
+				"services.service1.cluster-arn: added cluster-arn",
+				"services.service1.listener-rule-arn: added rule-arn",
+				"services.service1.canary.ecs-service: added service1-canary",
+				"services.service1.canary.tg-arn: added tg-canary",
+				"services.service1.primary.ecs-service: added service1",
+				"services.service1.primary.tg-arn: added tg-primary",
+			},
+		},
+		{
+			name: "drift",
+			raw: `region: us-east-1
+services:
+  service1:
+    cluster: production
+    listener-rule-arn: rule-arn
+    deploy-timeout: 10m
+    canary:
+      ecs-service: service1-canary
+      tg-name: service1-canary
+    primary:
+      ecs-service: service1
+      tg-arn: tg-old
+  service2:
+    listener-rule-arn: other-rule-arn
+`,
+			services: discovered,
+			want: `region: us-east-1
+services:
+  service1:
+    cluster: production
+    listener-rule-arn: rule-arn
+    deploy-timeout: 10m
+    canary:
+      ecs-service: service1-canary
+      tg-name: service1-canary
+    primary:
+      ecs-service: service1
+      tg-arn: tg-primary
+  service2:
+    listener-rule-arn: other-rule-arn
+`,
+			wantDrift: []string{
+				"services.service1.primary.tg-arn: tg-old -> tg-primary",
+				"services.service2: no resources are tagged for this service",
+			},
+		},
+		{
+			name: "templated",
+			raw: `# deploy settings for every service
+region: [[ .region | default "us-east-1" ]]
+services:
+  # the main api
+  service1:
+    cluster-arn: ${CLUSTER_ARN} # set by the pipeline
+    listener-rule-arn: arn:aws:elasticloadbalancing:us-east-1:123456789012:listener-rule/app/service1/0123456789abcdef/0123456789abcdef/0123456789abcdef
+    canary:
+      ecs-service: service1-canary
+      tg-arn: [[ .canaryTG ]]
+    primary:
+      ecs-service: "service1"
+      tg-arn: 'tg-old'
+    steps:
+      - wait
+`,
+			services: discovered,
+			want: `# deploy settings for every service
+region: [[ .region | default "us-east-1" ]]
+services:
+  # the main api
+  service1:
+    cluster-arn: ${CLUSTER_ARN} # set by the pipeline
+    listener-rule-arn: rule-arn
+    canary:
+      ecs-service: service1-canary
+      tg-arn: [[ .canaryTG ]]
+    primary:
+      ecs-service: "service1"
+      tg-arn: 'tg-primary'
+    steps:
+      - wait
+`,
+			wantDrift: []string{
+				"services.service1.cluster-arn: templated, left as is (discovered cluster-arn)",
+				"services.service1.listener-rule-arn: arn:aws:elasticloadbalancing:us-east-1:123456789012:listener-rule/app/service1/0123456789abcdef/0123456789abcdef/0123456789abcdef -> rule-arn",
+				"services.service1.canary.tg-arn: templated, left as is (discovered tg-canary)",
+				"services.service1.primary.tg-arn: tg-old -> tg-primary",
+			},
+		},
+		{
+			name: "included",
+			raw: `include:
+- services/*.yml
+`,
+			loaded: &config.Config{
+				Services: map[string]*config.ServiceConfig{"service1": {}},
+			},
+			services: discovered,
+			want: `include:
+- services/*.yml
+`,
+			wantDrift: []string{
+				"services.service1: defined in an included file, skipped",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, drift, err := Update([]byte(tt.raw), tt.loaded, tt.services)
+			if err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Update() got = %s, want %s", got, tt.want)
+			}
+
+			gotDrift := []string{}
+			for _, change := range drift {
+				gotDrift = append(gotDrift, change.String())
+			}
+
+			if !reflect.DeepEqual(gotDrift, tt.wantDrift) {
+				t.Errorf("Update() drift = %v, want %v", gotDrift, tt.wantDrift)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package discover
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+const (
+	// ServiceTag names the pompeii service an ecs service or target group belongs to
+	ServiceTag = "pompeii:service"
+	// PoolTag the pool, canary or primary, an ecs service or target group serves
+	PoolTag = "pompeii:pool"
+
+	describeServicesBatchSize = 10
+	describeTagsBatchSize     = 20
+)
+
+type (
+	// Pool what was discovered about one pool of a service
+	Pool struct {
+		ECSService     string
+		TargetGroupARN string
+	}
+
+	// Service what was discovered about a service
+	Service struct {
+		Name        string
+		ClusterARN  string
+		ListenerARN string
+		Canary      *Pool
+		Primary     *Pool
+	}
+
+	// Scanner finds the ecs services and target groups tagged with the pompeii convention
+	Scanner struct {
+		ecsSvc   ecsiface.ECSAPI
+		elbv2Svc elbv2iface.ELBV2API
+	}
+)
+
+// NewScanner returns a scanner using the given session
+func NewScanner(sess *session.Session) *Scanner {
+	return &Scanner{
+		ecsSvc:   ecs.New(sess),
+		elbv2Svc: elbv2.New(sess),
+	}
+}
+
+// Scan returns the tagged services of the cluster sorted by name. Target groups and the listener rule
+// forwarding to both pools are looked up on loadBalancer when one is given, otherwise across the region
+func (s *Scanner) Scan(cluster, loadBalancer string) ([]*Service, error) {
+	services := map[string]*Service{}
+
+	if err := s.scanECSServices(cluster, services); err != nil {
+		return nil, err
+	}
+
+	loadBalancerARN := ""
+	if loadBalancer != "" {
+		result, err := s.elbv2Svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+			Names: []*string{aws.String(loadBalancer)},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result.LoadBalancers) != 1 {
+			return nil, fmt.Errorf("found %d load balancers named %s", len(result.LoadBalancers), loadBalancer)
+		}
+		loadBalancerARN = aws.StringValue(result.LoadBalancers[0].LoadBalancerArn)
+	}
+
+	if err := s.scanTargetGroups(loadBalancerARN, services); err != nil {
+		return nil, err
+	}
+
+	if loadBalancerARN != "" {
+		if err := s.scanListenerRules(loadBalancerARN, services); err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := []*Service{}
+	for _, service := range services {
+		sorted = append(sorted, service)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	return sorted, nil
+}
+
+func (s *Scanner) scanECSServices(cluster string, services map[string]*Service) error {
+	arns := []*string{}
+	err := s.ecsSvc.ListServicesPages(&ecs.ListServicesInput{
+		Cluster: aws.String(cluster),
+	}, func(page *ecs.ListServicesOutput, lastPage bool) bool {
+		arns = append(arns, page.ServiceArns...)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(arns); start += describeServicesBatchSize {
+		end := start + describeServicesBatchSize
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		result, err := s.ecsSvc.DescribeServices(&ecs.DescribeServicesInput{
+			Cluster:  aws.String(cluster),
+			Services: arns[start:end],
+			Include:  []*string{aws.String(ecs.ServiceFieldTags)},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, ecsService := range result.Services {
+			tags := map[string]string{}
+			for _, tag := range ecsService.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+
+			service, pool := poolFor(services, tags)
+			if pool == nil {
+				continue
+			}
+
+			if pool.ECSService != "" {
+				return fmt.Errorf("%s and %s are both tagged as the %s pool of %s", pool.ECSService,
+					aws.StringValue(ecsService.ServiceName), tags[PoolTag], service.Name)
+			}
+
+			service.ClusterARN = aws.StringValue(ecsService.ClusterArn)
+			pool.ECSService = aws.StringValue(ecsService.ServiceName)
+			if len(ecsService.LoadBalancers) > 0 {
+				pool.TargetGroupARN = aws.StringValue(ecsService.LoadBalancers[0].TargetGroupArn)
+			}
+		}
+	}
+
+	return nil
+}
+
+// scanTargetGroups applies the pools of tagged target groups, which take precedence over the target group an ecs service registers with
+func (s *Scanner) scanTargetGroups(loadBalancerARN string, services map[string]*Service) error {
+	input := &elbv2.DescribeTargetGroupsInput{}
+	if loadBalancerARN != "" {
+		input.LoadBalancerArn = aws.String(loadBalancerARN)
+	}
+
+	arns := []*string{}
+	err := s.elbv2Svc.DescribeTargetGroupsPages(input, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+		for _, targetGroup := range page.TargetGroups {
+			arns = append(arns, targetGroup.TargetGroupArn)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	tagged := map[*Pool]string{}
+	for start := 0; start < len(arns); start += describeTagsBatchSize {
+		end := start + describeTagsBatchSize
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		result, err := s.elbv2Svc.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: arns[start:end]})
+		if err != nil {
+			return err
+		}
+
+		for _, description := range result.TagDescriptions {
+			tags := map[string]string{}
+			for _, tag := range description.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+
+			service, pool := poolFor(services, tags)
+			if pool == nil {
+				continue
+			}
+
+			arn := aws.StringValue(description.ResourceArn)
+			if existing, ok := tagged[pool]; ok {
+				return fmt.Errorf("%s and %s are both tagged as the %s pool of %s", existing, arn, tags[PoolTag], service.Name)
+			}
+
+			tagged[pool] = arn
+			pool.TargetGroupARN = arn
+		}
+	}
+
+	return nil
+}
+
+// scanListenerRules finds the rule of each service that forwards to both its canary and primary target groups
+func (s *Scanner) scanListenerRules(loadBalancerARN string, services map[string]*Service) error {
+	listeners := []*elbv2.Listener{}
+	err := s.elbv2Svc.DescribeListenersPages(&elbv2.DescribeListenersInput{
+		LoadBalancerArn: aws.String(loadBalancerARN),
+	}, func(page *elbv2.DescribeListenersOutput, lastPage bool) bool {
+		listeners = append(listeners, page.Listeners...)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	rules := []*elbv2.Rule{}
+	for _, listener := range listeners {
+		input := &elbv2.DescribeRulesInput{ListenerArn: listener.ListenerArn}
+		for {
+			result, err := s.elbv2Svc.DescribeRules(input)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, result.Rules...)
+
+			if aws.StringValue(result.NextMarker) == "" {
+				break
+			}
+			input.Marker = result.NextMarker
+		}
+	}
+
+	for _, service := range services {
+		if service.Canary == nil || service.Primary == nil {
+			continue
+		}
+
+		for _, rule := range rules {
+			if forwardsTo(rule, service.Canary.TargetGroupARN) && forwardsTo(rule, service.Primary.TargetGroupARN) {
+				if service.ListenerARN != "" {
+					return fmt.Errorf("%s and %s both forward to the pools of %s", service.ListenerARN, aws.StringValue(rule.RuleArn), service.Name)
+				}
+				service.ListenerARN = aws.StringValue(rule.RuleArn)
+			}
+		}
+	}
+
+	return nil
+}
+
+// poolFor returns the service and pool named by the pompeii tags, creating them as needed, or a nil pool when the tags don't name one
+func poolFor(services map[string]*Service, tags map[string]string) (*Service, *Pool) {
+	name := tags[ServiceTag]
+	if name == "" {
+		return nil, nil
+	}
+
+	service, ok := services[name]
+	if !ok {
+		service = &Service{Name: name}
+	}
+
+	var pool *Pool
+	switch tags[PoolTag] {
+	case "canary":
+		if service.Canary == nil {
+			service.Canary = &Pool{}
+		}
+		pool = service.Canary
+	case "primary":
+		if service.Primary == nil {
+			service.Primary = &Pool{}
+		}
+		pool = service.Primary
+	default:
+		return nil, nil
+	}
+
+	services[name] = service
+	return service, pool
+}
+
+func forwardsTo(rule *elbv2.Rule, targetGroupARN string) bool {
+	if targetGroupARN == "" {
+		return false
+	}
+
+	for _, action := range rule.Actions {
+		if action.ForwardConfig == nil {
+			continue
+		}
+
+		for _, targetGroup := range action.ForwardConfig.TargetGroups {
+			if aws.StringValue(targetGroup.TargetGroupArn) == targetGroupARN {
+				return true
+			}
+		}
+	}
+
+	return false
+}
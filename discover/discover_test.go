@@ -0,0 +1,157 @@
+package discover
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+type (
+	fakeECS struct {
+		ecsiface.ECSAPI
+		services []*ecs.Service
+	}
+
+	fakeELBV2 struct {
+		elbv2iface.ELBV2API
+		tags  []*elbv2.TagDescription
+		rules []*elbv2.Rule
+	}
+)
+
+func (f *fakeECS) ListServicesPages(input *ecs.ListServicesInput, fn func(*ecs.ListServicesOutput, bool) bool) error {
+	output := &ecs.ListServicesOutput{}
+	for _, service := range f.services {
+		output.ServiceArns = append(output.ServiceArns, service.ServiceArn)
+	}
+	fn(output, true)
+	return nil
+}
+
+func (f *fakeECS) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	return &ecs.DescribeServicesOutput{Services: f.services}, nil
+}
+
+func (f *fakeELBV2) DescribeLoadBalancers(input *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	return &elbv2.DescribeLoadBalancersOutput{
+		LoadBalancers: []*elbv2.LoadBalancer{{LoadBalancerArn: aws.String("alb-arn")}},
+	}, nil
+}
+
+func (f *fakeELBV2) DescribeTargetGroupsPages(input *elbv2.DescribeTargetGroupsInput, fn func(*elbv2.DescribeTargetGroupsOutput, bool) bool) error {
+	output := &elbv2.DescribeTargetGroupsOutput{}
+	for _, description := range f.tags {
+		output.TargetGroups = append(output.TargetGroups, &elbv2.TargetGroup{TargetGroupArn: description.ResourceArn})
+	}
+	fn(output, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	return &elbv2.DescribeTagsOutput{TagDescriptions: f.tags}, nil
+}
+
+func (f *fakeELBV2) DescribeListenersPages(input *elbv2.DescribeListenersInput, fn func(*elbv2.DescribeListenersOutput, bool) bool) error {
+	fn(&elbv2.DescribeListenersOutput{Listeners: []*elbv2.Listener{{ListenerArn: aws.String("listener-arn")}}}, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeRules(input *elbv2.DescribeRulesInput) (*elbv2.DescribeRulesOutput, error) {
+	return &elbv2.DescribeRulesOutput{Rules: f.rules}, nil
+}
+
+func ecsService(name, service, pool, targetGroup string) *ecs.Service {
+	return &ecs.Service{
+		ServiceArn:    aws.String("arn:" + name),
+		ServiceName:   aws.String(name),
+		ClusterArn:    aws.String("cluster-arn"),
+		LoadBalancers: []*ecs.LoadBalancer{{TargetGroupArn: aws.String(targetGroup)}},
+		Tags:          []*ecs.Tag{{Key: aws.String(ServiceTag), Value: aws.String(service)}, {Key: aws.String(PoolTag), Value: aws.String(pool)}},
+	}
+}
+
+func targetGroup(arn, service, pool string) *elbv2.TagDescription {
+	return &elbv2.TagDescription{
+		ResourceArn: aws.String(arn),
+		Tags:        []*elbv2.Tag{{Key: aws.String(ServiceTag), Value: aws.String(service)}, {Key: aws.String(PoolTag), Value: aws.String(pool)}},
+	}
+}
+
+func forwardRule(arn string, targetGroups ...string) *elbv2.Rule {
+	forward := &elbv2.ForwardActionConfig{}
+	for _, targetGroup := range targetGroups {
+		forward.TargetGroups = append(forward.TargetGroups, &elbv2.TargetGroupTuple{TargetGroupArn: aws.String(targetGroup)})
+	}
+
+	return &elbv2.Rule{
+		RuleArn: aws.String(arn),
+		Actions: []*elbv2.Action{{Type: aws.String("forward"), ForwardConfig: forward}},
+	}
+}
+
+func TestScanner_Scan(t *testing.T) {
+	tests := []struct {
+		name         string
+		ecsSvc       *fakeECS
+		elbv2Svc     *fakeELBV2
+		loadBalancer string
+		want         []*Service
+		wantErr      bool
+	}{
+		{
+			name: "tagged",
+			ecsSvc: &fakeECS{services: []*ecs.Service{
+				ecsService("app-canary", "app", "canary", "tg-service-canary"),
+				ecsService("app", "app", "primary", "tg-service-primary"),
+				{ServiceArn: aws.String("arn:untagged"), ServiceName: aws.String("untagged")},
+			}},
+			elbv2Svc: &fakeELBV2{
+				tags: []*elbv2.TagDescription{
+					targetGroup("tg-canary", "app", "canary"),
+					{ResourceArn: aws.String("tg-untagged")},
+				},
+				rules: []*elbv2.Rule{
+					forwardRule("rule-other", "tg-untagged"),
+					forwardRule("rule-app", "tg-canary", "tg-service-primary"),
+				},
+			},
+			loadBalancer: "alb",
+			want: []*Service{
+				{
+					Name:        "app",
+					ClusterARN:  "cluster-arn",
+					ListenerARN: "rule-app",
+					Canary:      &Pool{ECSService: "app-canary", TargetGroupARN: "tg-canary"},
+					Primary:     &Pool{ECSService: "app", TargetGroupARN: "tg-service-primary"},
+				},
+			},
+		},
+		{
+			name: "duplicate_pool",
+			ecsSvc: &fakeECS{services: []*ecs.Service{
+				ecsService("app-canary", "app", "canary", "tg-canary"),
+				ecsService("app-canary-2", "app", "canary", "tg-canary"),
+			}},
+			elbv2Svc: &fakeELBV2{},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := &Scanner{ecsSvc: tt.ecsSvc, elbv2Svc: tt.elbv2Svc}
+			got, err := scanner.Scan("cluster", tt.loadBalancer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scan() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
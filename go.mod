@@ -3,8 +3,10 @@ module github.com/chriskuchin/pompeii
 go 1.14
 
 require (
-	github.com/aws/aws-sdk-go v1.34.0
-	github.com/prometheus/common v0.10.0
+	github.com/aws/aws-sdk-go v1.38.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/sirupsen/logrus v1.4.2
 	github.com/urfave/cli/v2 v2.2.0
 	gopkg.in/yaml.v2 v2.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Fields structured fields attached to a log line
+	Fields = logrus.Fields
+
+	// Entry a logger carrying fields
+	Entry = logrus.Entry
+)
+
+// Field names shared by every package so log lines can be correlated
+const (
+	RunID     = "run_id"
+	Service   = "service"
+	Workflow  = "workflow"
+	Pool      = "pool"
+	Step      = "step"
+	Action    = "action"
+	RequestID = "aws_request_id"
+	Operation = "aws_operation"
+)
+
+var (
+	// Logger the logger every package writes through
+	Logger = newLogger()
+)
+
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = os.Stderr
+	logger.Formatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	logger.Level = logrus.InfoLevel
+
+	return logger
+}
+
+// Configure sets the output format (json or logfmt) and minimum level of the logger
+func Configure(format, level string) error {
+	switch strings.ToLower(format) {
+	case "json":
+		Logger.Formatter = &logrus.JSONFormatter{}
+	case "logfmt", "text", "":
+		Logger.Formatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+
+	if level == "" {
+		return nil
+	}
+
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	Logger.Level = parsed
+
+	return nil
+}
+
+// WithFields returns a logger carrying fields
+func WithFields(fields Fields) *Entry {
+	return Logger.WithFields(fields)
+}
+
+// Debug logs at debug level
+func Debug(args ...interface{}) { Logger.Debug(args...) }
+
+// Debugf logs at debug level
+func Debugf(format string, args ...interface{}) { Logger.Debugf(format, args...) }
+
+// Info logs at info level
+func Info(args ...interface{}) { Logger.Info(args...) }
+
+// Infof logs at info level
+func Infof(format string, args ...interface{}) { Logger.Infof(format, args...) }
+
+// Warnf logs at warn level
+func Warnf(format string, args ...interface{}) { Logger.Warnf(format, args...) }
+
+// Error logs at error level
+func Error(args ...interface{}) { Logger.Error(args...) }
+
+// Errorf logs at error level
+func Errorf(format string, args ...interface{}) { Logger.Errorf(format, args...) }
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   string
+		wantErr bool
+	}{
+		{name: "json", format: "json", level: "debug"},
+		{name: "logfmt", format: "logfmt", level: "info"},
+		{name: "unknown_format", format: "xml", wantErr: true},
+		{name: "unknown_level", format: "json", level: "loud", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Configure(tt.format, tt.level); (err != nil) != tt.wantErr {
+				t.Errorf("Configure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithFields_JSON(t *testing.T) {
+	if err := Configure("json", "info"); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure("logfmt", "info")
+
+	out := &bytes.Buffer{}
+	Logger.Out = out
+
+	WithFields(Fields{RunID: "run1", Service: "service1", Step: 2}).Info("shifted")
+
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("log line is not json: %s", out)
+	}
+
+	if got[RunID] != "run1" || got[Service] != "service1" || got[Step] != float64(2) || got["msg"] != "shifted" {
+		t.Errorf("log line = %v", got)
+	}
+}
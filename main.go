@@ -1,13 +1,29 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/chriskuchin/pompeii/audit"
 	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/discover"
+	"github.com/chriskuchin/pompeii/logging"
+	"github.com/chriskuchin/pompeii/notify"
+	"github.com/chriskuchin/pompeii/release"
+	"github.com/chriskuchin/pompeii/report"
+	"github.com/chriskuchin/pompeii/server"
 	"github.com/chriskuchin/pompeii/workflow"
-	"github.com/prometheus/common/log"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
 )
 
 type ()
@@ -24,20 +40,35 @@ func main() {
 				Value: "config.yml",
 			},
 			&cli.StringFlag{
-				Name:     "service",
-				Required: true,
+				Name: "service",
 			},
 			&cli.StringFlag{
 				Name: "s3-bucket",
 			},
+			&cli.StringSliceFlag{
+				Name:  "var",
+				Usage: "key=value made available to config templates as [[ .key ]], may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "log-format",
+				Usage: "json or logfmt",
+				Value: "logfmt",
+			},
+			&cli.StringFlag{
+				Name:  "log-level",
+				Value: "info",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return logging.Configure(c.String("log-format"), c.String("log-level"))
 		},
 		Commands: []*cli.Command{
 			{
 				Name: "deploy",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "workflow",
-						Value: "default",
+						Usage: "defaults to the workflow of the service, or default",
 					},
 					&cli.StringFlag{
 						Name:     "task-def",
@@ -45,43 +76,502 @@ func main() {
 					},
 					&cli.Int64Flag{
 						Name:  "count",
-						Value: 2,
+						Usage: "task count of both pools, each pool keeps its current count when unset",
 					},
-				},
+					&cli.Int64Flag{
+						Name:  "canary-count",
+						Usage: "task count of the canary pool, overrides --count",
+					},
+					&cli.Int64Flag{
+						Name:  "primary-count",
+						Usage: "task count of the primary pool, overrides --count",
+					},
+					&cli.StringSliceFlag{
+						Name:  "param",
+						Usage: "key=value bound to a parameter of the workflow, may be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "write a report of the deployment to this path",
+					},
+					&cli.StringFlag{
+						Name:  "report-format",
+						Usage: "json or junit, defaults to junit for .xml paths and json otherwise",
+					},
+				}, freezeFlags()...),
 				Action: func(c *cli.Context) error {
-					settings := initClient(c)
-					fmt.Println(settings)
-					return workflow.ProcessWorkflow(&config.Workflow{
-						Config:  settings,
-						Service: c.String("service"),
-						Steps:   settings.Workflows[c.String("workflow")],
+					if c.String("service") == "" {
+						return fmt.Errorf("Required flag \"service\" not set")
+					}
+
+					override, err := overrideReason(c)
+					if err != nil {
+						return err
+					}
+
+					settings, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+					logging.Debugf("[deploy] config: %+v", settings)
+
+					if _, ok := settings.Services[c.String("service")]; !ok {
+						return fmt.Errorf("unknown service: %s", c.String("service"))
+					}
+
+					workflowName := c.String("workflow")
+					if workflowName == "" {
+						workflowName = settings.GetWorkflow(c.String("service"))
+					}
+
+					definition := settings.GetWorkflowDefinition(c.String("service"), workflowName)
+					if definition == nil {
+						return fmt.Errorf("unknown workflow: %s", workflowName)
+					}
+
+					steps, parameters, err := definition.Bind(parseVars(c.StringSlice("param")))
+					if err != nil {
+						return err
+					}
+
+					processor, err := workflow.NewProcessor(&config.Workflow{
+						Config:         settings,
+						Service:        c.String("service"),
+						Name:           workflowName,
+						Parameters:     parameters,
+						RollbackPolicy: definition.RollbackPolicy,
+						OverrideReason: override,
+						Steps:          steps,
 						Default: &config.ServiceState{
 							TaskDef: c.String("task-def"),
 							Count:   c.Int64("count"),
 						},
+						PoolDefaults: map[string]*config.ServiceState{
+							"canary":  {Count: c.Int64("canary-count")},
+							"primary": {Count: c.Int64("primary-count")},
+						},
 					})
+					if err != nil {
+						return err
+					}
+
+					runErr := processor.Run()
+
+					if path := c.String("report"); path != "" {
+						if err := report.WriteFile(path, c.String("report-format"), processor.Status()); err != nil {
+							logging.Errorf("Failed to write report: %s", err)
+						}
+					}
+
+					return runErr
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "list past workflow runs of a service",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "run",
+						Usage: "show the full record of a single run",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.String("service") == "" {
+						return fmt.Errorf("Required flag \"service\" not set")
+					}
+
+					settings, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					store := audit.NewStore(settings, c.String("service"))
+					if store == nil {
+						return fmt.Errorf("auditing is not configured")
+					}
+
+					records, err := store.List(c.String("service"))
+					if err != nil {
+						return err
+					}
+
+					return printHistory(os.Stdout, records, c.String("run"))
 				},
 			},
+			{
+				Name:      "continue",
+				Usage:     "resume a paused workflow run at the step after its pause",
+				ArgsUsage: "<run-id>",
+				Flags:     freezeFlags(),
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a run id")
+					}
+
+					override, err := overrideReason(c)
+					if err != nil {
+						return err
+					}
+
+					settings, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					return workflow.Continue(settings, c.Args().First(), override)
+				},
+			},
+			{
+				Name:      "abort",
+				Usage:     "roll a paused workflow run back to its checkpoint",
+				ArgsUsage: "<run-id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "reason",
+						Value: "Aborted",
+						Usage: "why the run was aborted, recorded with its rollback",
+					},
+					&cli.BoolFlag{
+						Name:  "expired",
+						Usage: "abort every paused run past its auto-abort deadline instead of a single run",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					settings, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					if c.Bool("expired") {
+						aborted, err := workflow.AbortExpired(settings)
+						for _, id := range aborted {
+							logging.Infof("Aborted run: %s", id)
+						}
+						return err
+					}
+
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a run id")
+					}
+
+					return workflow.Abort(settings, c.Args().First(), c.String("reason"))
+				},
+			},
+			{
+				Name:  "rollback",
+				Usage: "restore an earlier task definition revision to both pools",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "revision number, family:revision, task definition arn or previous-N",
+					},
+					&cli.BoolFlag{
+						Name:  "list",
+						Usage: "list the revisions available to roll back to",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					service := c.String("service")
+					if service == "" {
+						return fmt.Errorf("Required flag \"service\" not set")
+					}
+
+					settings, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					client := release.NewClient(settings)
+					if err := client.ResolveARNs(service); err != nil {
+						return err
+					}
+
+					revisions, err := client.ListTaskDefinitionRevisions(service, "primary")
+					if err != nil {
+						return err
+					}
+
+					primary := client.GetCurrentServiceState(service, "primary")
+					canary := client.GetCurrentServiceState(service, "canary")
+					if primary == nil || canary == nil {
+						return fmt.Errorf("Failed to read the current state of %s", service)
+					}
+
+					if c.Bool("list") || c.String("to") == "" {
+						return printRevisions(os.Stdout, revisions, primary.TaskDef)
+					}
+
+					target, err := release.SelectRevision(revisions, primary.TaskDef, c.String("to"))
+					if err != nil {
+						return err
+					}
+
+					logging.Infof("Rolling back %s from %s to %s registered: %s images: %s", service, primary.TaskDef, target.ARN,
+						target.RegisteredAt.Format(time.RFC3339), strings.Join(target.Images, ", "))
+
+					processor, err := workflow.NewProcessor(&config.Workflow{
+						Config:   settings,
+						Service:  service,
+						Name:     "rollback",
+						Rollback: true,
+						Steps:    workflow.RollbackSteps(target.ARN, canary.Count, primary.Count, settings.GetServiceValidationTask(service) != ""),
+						Default: &config.ServiceState{
+							TaskDef: target.ARN,
+							Count:   primary.Count,
+						},
+					})
+					if err != nil {
+						return err
+					}
+
+					return processor.Run()
+				},
+			},
+			{
+				Name:  "discover",
+				Usage: "generate the services section of the config from resources tagged " + discover.ServiceTag + " and " + discover.PoolTag,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "cluster",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "load-balancer",
+						Usage: "only look for target groups and listener rules on this load balancer",
+					},
+					&cli.StringFlag{
+						Name:  "region",
+						Usage: "defaults to the region of the config",
+					},
+					&cli.BoolFlag{
+						Name:  "write",
+						Usage: "update the config file in place instead of printing it",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					path := c.String("config-file")
+					if c.String("s3-bucket") != "" {
+						return fmt.Errorf("discover only supports local config files")
+					}
+
+					raw, err := ioutil.ReadFile(path)
+					if err != nil && !os.IsNotExist(err) {
+						return err
+					}
+
+					loaded := config.NewConfigFromFile(path, parseVars(c.StringSlice("var")))
+
+					awsConfig := &aws.Config{}
+					if region := c.String("region"); region != "" {
+						awsConfig.Region = aws.String(region)
+					} else if loaded != nil && loaded.Region != "" {
+						awsConfig.Region = aws.String(loaded.Region)
+					}
+
+					services, err := discover.NewScanner(session.New(awsConfig)).Scan(c.String("cluster"), c.String("load-balancer"))
+					if err != nil {
+						return err
+					}
+
+					updated, drift, err := discover.Update(raw, loaded, services)
+					if err != nil {
+						return err
+					}
+
+					for _, change := range drift {
+						fmt.Fprintln(os.Stderr, change)
+					}
+
+					if c.Bool("write") {
+						return ioutil.WriteFile(path, updated, 0644)
+					}
+
+					_, err = os.Stdout.Write(updated)
+					return err
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "inspect the loaded config",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "show",
+						Usage: "print the effective config of a service with every default applied",
+						Action: func(c *cli.Context) error {
+							service := c.String("service")
+							if service == "" {
+								return fmt.Errorf("Required flag \"service\" not set")
+							}
+
+							settings, err := loadConfig(c)
+							if err != nil {
+								return err
+							}
+
+							if _, ok := settings.Services[service]; !ok {
+								return fmt.Errorf("unknown service: %s", service)
+							}
+
+							out, err := yaml.Marshal(settings.GetServiceConfig(service))
+							if err != nil {
+								return err
+							}
+
+							_, err = os.Stdout.Write(out)
+							return err
+						},
+					},
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "serve the deployment api",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "listen",
+						Value: ":8080",
+					},
+					&cli.StringFlag{
+						Name:     "token",
+						EnvVars:  []string{"POMPEII_API_TOKEN"},
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					settings, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					return server.New(settings, c.String("token")).ListenAndServe(c.String("listen"))
+				},
+			},
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		logging.Errorf("[main] %s", err)
+
+		// a failed rollback leaves the service needing attention, which callers can tell apart from a failed deploy
+		var rollbackErr *workflow.RollbackError
+		if errors.As(err, &rollbackErr) {
+			os.Exit(3)
+		}
+		os.Exit(1)
+	}
+}
+
+// freezeFlags let a workflow run while its service is frozen or outside of its deploy windows
+func freezeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "override-freeze",
+			Usage: "run even when the service is frozen or outside of its deploy windows, requires --reason",
+		},
+		&cli.StringFlag{
+			Name:  "reason",
+			Usage: "why the freeze is overridden, recorded in the audit trail",
 		},
 	}
+}
+
+// overrideReason returns why deploy windows and freezes are overridden, or "" when they are enforced
+func overrideReason(c *cli.Context) (string, error) {
+	if !c.Bool("override-freeze") {
+		return "", nil
+	}
+
+	if strings.TrimSpace(c.String("reason")) == "" {
+		return "", fmt.Errorf("--override-freeze requires --reason")
+	}
 
-	app.Run(os.Args)
+	return c.String("reason"), nil
 }
 
-func initClient(c *cli.Context) *config.Config {
+// loadConfig reads the config without resolving any arns against aws, each run resolves those of its own service
+func loadConfig(c *cli.Context) (*config.Config, error) {
 	filePath := c.String("config-file")
 	s3Bucket := c.String("s3-bucket")
+	vars := parseVars(c.StringSlice("var"))
 
-	log.Debugf("[InitClient] Loading config. path: %s bucket: %s", filePath, s3Bucket)
+	logging.Debugf("[InitClient] Loading config. path: %s bucket: %s", filePath, s3Bucket)
 
 	clientConfig := &config.Config{}
 	if s3Bucket != "" {
-		clientConfig = config.NewConfigFromS3(filePath, s3Bucket)
+		clientConfig = config.NewConfigFromS3(filePath, s3Bucket, vars)
 	} else {
-		clientConfig = config.NewConfigFromFile(filePath)
+		clientConfig = config.NewConfigFromFile(filePath, vars)
+	}
+
+	if clientConfig == nil {
+		return nil, fmt.Errorf("failed to load config %s", filePath)
+	}
+
+	if err := notify.Validate(clientConfig); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %s", filePath, err)
+	}
+
+	return clientConfig, nil
+}
+
+// parseVars splits key=value pairs, a pair without a value sets the key to an empty string
+func parseVars(pairs []string) map[string]string {
+	vars := map[string]string{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		} else {
+			vars[parts[0]] = ""
+		}
+	}
+
+	return vars
+}
+
+func printHistory(out io.Writer, records []*audit.Record, runID string) error {
+	if runID != "" {
+		for _, record := range records {
+			if record.RunID == runID {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(record)
+			}
+		}
+
+		return fmt.Errorf("run not found: %s", runID)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "STARTED\tRUN\tWORKFLOW\tOUTCOME\tDURATION\tACTOR\tPRIMARY TASK DEF")
+	for _, record := range records {
+		outcome := record.Outcome
+		if record.Overridden != "" {
+			outcome += " (freeze overridden)"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s -> %s\n",
+			record.StartedAt.Format(time.RFC3339), record.RunID, record.Workflow, outcome,
+			record.Duration.Round(time.Second), record.Actor, record.PrimaryBefore, record.PrimaryAfter)
+	}
+
+	return writer.Flush()
+}
+
+func printRevisions(out io.Writer, revisions []*release.TaskDefinitionRevision, current string) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "REVISION\tREGISTERED\tIMAGES\t")
+	for _, revision := range revisions {
+		marker := ""
+		if revision.ARN == current {
+			marker = "(current)"
+		}
+
+		fmt.Fprintf(writer, "%s:%d\t%s\t%s\t%s\n", revision.Family, revision.Revision,
+			revision.RegisteredAt.Format(time.RFC3339), strings.Join(revision.Images, ", "), marker)
 	}
 
-	return clientConfig
+	return writer.Flush()
 }
 
 // 1. shift all traffic to primary pool
@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Every run pushes to the pushgateway group of its service and workflow, replacing the values the previous run of that
+// workflow pushed, so the metrics are gauges describing the most recent run rather than counters. Counts across runs
+// come from the history Prometheus scrapes, for example:
+//
+//	time() - pompeii_workflow_success_timestamp_seconds                            time since the last successful deploy
+//	pompeii_workflow_status{status="rolled-back"} == 1                             services whose last run rolled back
+//	changes(pompeii_workflow_start_timestamp_seconds[7d])                          runs started in the last week
+//	changes(pompeii_workflow_rollback_timestamp_seconds[7d])                       rollbacks in the last week
+//	max_over_time(pompeii_workflow_duration_seconds[7d])                           slowest run in the last week
+type (
+	// Recorder the metrics of a single run, kept in a registry of its own so concurrent runs don't overwrite each
+	// other's values and a push only carries the activity of its run
+	Recorder struct {
+		Registry *prometheus.Registry
+
+		workflowStart      prometheus.Gauge
+		workflowFinish     prometheus.Gauge
+		workflowSuccess    prometheus.Gauge
+		workflowRollback   prometheus.Gauge
+		workflowStatus     *prometheus.GaugeVec
+		workflowDuration   prometheus.Gauge
+		stepDuration       *prometheus.GaugeVec
+		awsRequests        *prometheus.GaugeVec
+		awsRequestErrors   *prometheus.GaugeVec
+		awsRequestDuration *prometheus.GaugeVec
+		canaryWeight       prometheus.Gauge
+	}
+)
+
+// NewRecorder returns the recorder of a run. Gauges are only gathered once they are set, so a run doesn't push a zero
+// over a value it knows nothing about, like the success timestamp of a failed run
+func NewRecorder() *Recorder {
+	m := &Recorder{
+		Registry: prometheus.NewRegistry(),
+
+		workflowStart: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pompeii_workflow_start_timestamp_seconds",
+			Help: "When the last run of the workflow started.",
+		}),
+
+		workflowFinish: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pompeii_workflow_finish_timestamp_seconds",
+			Help: "When the last run of the workflow finished.",
+		}),
+
+		workflowSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pompeii_workflow_success_timestamp_seconds",
+			Help: "When the workflow last completed every step, only pushed by successful runs.",
+		}),
+
+		workflowRollback: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pompeii_workflow_rollback_timestamp_seconds",
+			Help: "When the workflow was last rolled back to its checkpoint, only pushed by rolled back runs.",
+		}),
+
+		workflowStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pompeii_workflow_status",
+			Help: "1 for the status the last run of the workflow finished with.",
+		}, []string{"status"}),
+
+		workflowDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pompeii_workflow_duration_seconds",
+			Help: "Time taken by the last run of the workflow.",
+		}),
+
+		stepDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pompeii_step_duration_seconds",
+			Help: "Time taken by each step of the last run of the workflow.",
+		}, []string{"step", "action", "status"}),
+
+		awsRequests: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pompeii_aws_requests",
+			Help: "AWS api calls made by the last run of the workflow.",
+		}, []string{"aws_service", "operation"}),
+
+		awsRequestErrors: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pompeii_aws_request_errors",
+			Help: "AWS api calls made by the last run of the workflow that returned an error.",
+		}, []string{"aws_service", "operation"}),
+
+		awsRequestDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pompeii_aws_request_duration_seconds",
+			Help: "Total latency of the AWS api calls made by the last run of the workflow.",
+		}, []string{"aws_service", "operation"}),
+
+		canaryWeight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pompeii_canary_weight",
+			Help: "Canary target group weight when the workflow finished.",
+		}),
+	}
+
+	// vectors have nothing to gather until a value is set
+	m.Registry.MustRegister(
+		m.workflowStatus,
+		m.stepDuration,
+		m.awsRequests,
+		m.awsRequestErrors,
+		m.awsRequestDuration,
+	)
+
+	return m
+}
+
+// WorkflowFinished records the outcome, start and duration of the run. A resumed run reports when it first started
+func (m *Recorder) WorkflowFinished(status string, started, finished time.Time) {
+	if !started.IsZero() {
+		m.set(m.workflowStart, timestamp(started))
+		m.set(m.workflowDuration, finished.Sub(started).Seconds())
+	}
+	m.set(m.workflowFinish, timestamp(finished))
+
+	switch status {
+	case "succeeded":
+		m.set(m.workflowSuccess, timestamp(finished))
+	case "rolled-back":
+		m.set(m.workflowRollback, timestamp(finished))
+	}
+
+	m.workflowStatus.Reset()
+	m.workflowStatus.WithLabelValues(status).Set(1)
+}
+
+// StepFinished records the outcome and duration of a workflow step
+func (m *Recorder) StepFinished(step int, action, status string, duration time.Duration) {
+	m.stepDuration.WithLabelValues(strconv.Itoa(step), action, status).Set(duration.Seconds())
+}
+
+// CanaryWeight records the canary weight of the service
+func (m *Recorder) CanaryWeight(weight int64) {
+	m.set(m.canaryWeight, float64(weight))
+}
+
+// RequestCompleted records the latency and error of an api call, see release.Client
+func (m *Recorder) RequestCompleted(r *request.Request) {
+	m.awsRequests.WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name).Inc()
+	m.awsRequestDuration.WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name).Add(time.Since(r.Time).Seconds())
+	if r.Error != nil {
+		m.awsRequestErrors.WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name).Inc()
+	}
+}
+
+// set sets the gauge, registering it the first time so only gauges with a value are pushed
+func (m *Recorder) set(gauge prometheus.Gauge, value float64) {
+	gauge.Set(value)
+	if err := m.Registry.Register(gauge); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			logging.Errorf("[metrics.set] Failed to register gauge: %s", err)
+		}
+	}
+}
+
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// Push sends the metrics of the run to the configured pushgateway, doing nothing when none is configured. The
+// service and workflow are added to the grouping key so a push only replaces the previous run of the same workflow
+func (m *Recorder) Push(metricsConfig *config.MetricsConfig, service, workflow string) error {
+	if metricsConfig == nil || metricsConfig.PushgatewayURL == "" {
+		return nil
+	}
+
+	job := metricsConfig.Job
+	if job == "" {
+		job = "pompeii"
+	}
+
+	pusher := push.New(metricsConfig.PushgatewayURL, job).Gatherer(m.Registry)
+
+	// sort the labels so the grouping key is stable between runs
+	labels := []string{}
+	for label := range metricsConfig.Grouping {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		pusher = pusher.Grouping(label, metricsConfig.Grouping[label])
+	}
+	pusher = pusher.Grouping("service", service).Grouping("workflow", workflow)
+
+	logging.Debugf("[metrics.Push] Pushing metrics to %s job: %s", metricsConfig.PushgatewayURL, job)
+	return pusher.Add()
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+func TestPush(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		path = r.URL.Path
+		body = string(raw)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	m := NewRecorder()
+	m.StepFinished(1, "shift", "succeeded", 2*time.Second)
+	m.WorkflowFinished("succeeded", time.Now().Add(-time.Minute), time.Now())
+	m.CanaryWeight(10)
+
+	err := m.Push(&config.MetricsConfig{
+		PushgatewayURL: server.URL,
+		Job:            "deploys",
+		Grouping: map[string]string{
+			"env": "prod",
+		},
+	}, "service1", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pushgateway client orders grouping labels at random
+	for _, component := range []string{"/metrics/job/deploys/", "/env/prod", "/service/service1", "/workflow/default"} {
+		if !strings.Contains(path, component) {
+			t.Errorf("Push() path = %s, missing %s", path, component)
+		}
+	}
+
+	if body == "" {
+		t.Fatal("Push() sent an empty body")
+	}
+}
+
+// gathered returns the names of the metrics the recorder would push
+func gathered(t *testing.T, m *Recorder) map[string]bool {
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+
+	return names
+}
+
+func TestRecorder_WorkflowFinished(t *testing.T) {
+	started := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		status  string
+		started time.Time
+		want    []string
+		wantNot []string
+	}{
+		{
+			name:    "succeeded",
+			status:  "succeeded",
+			started: started,
+			want:    []string{"pompeii_workflow_start_timestamp_seconds", "pompeii_workflow_success_timestamp_seconds", "pompeii_workflow_duration_seconds"},
+			wantNot: []string{"pompeii_workflow_rollback_timestamp_seconds"},
+		},
+		{
+			name:    "failed",
+			status:  "failed",
+			started: started,
+			want:    []string{"pompeii_workflow_finish_timestamp_seconds", "pompeii_workflow_status"},
+			wantNot: []string{"pompeii_workflow_success_timestamp_seconds", "pompeii_workflow_rollback_timestamp_seconds", "pompeii_canary_weight"},
+		},
+		{
+			name:    "never_started",
+			status:  "blocked",
+			want:    []string{"pompeii_workflow_finish_timestamp_seconds"},
+			wantNot: []string{"pompeii_workflow_start_timestamp_seconds", "pompeii_workflow_duration_seconds"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewRecorder()
+			m.WorkflowFinished(tt.status, tt.started, time.Now())
+
+			names := gathered(t, m)
+			for _, name := range tt.want {
+				if !names[name] {
+					t.Errorf("Gather() is missing %s", name)
+				}
+			}
+			for _, name := range tt.wantNot {
+				if names[name] {
+					t.Errorf("Gather() has %s, a push would overwrite the value of an earlier run", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRecorder_WorkflowFinished_status(t *testing.T) {
+	m := NewRecorder()
+	m.WorkflowFinished("rolled-back", time.Now(), time.Now())
+	m.WorkflowFinished("succeeded", time.Now(), time.Now())
+
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "pompeii_workflow_status" {
+			continue
+		}
+
+		statuses := family.GetMetric()
+		if len(statuses) != 1 || statuses[0].GetLabel()[0].GetValue() != "succeeded" {
+			t.Errorf("pompeii_workflow_status = %v, want only the last status", statuses)
+		}
+		return
+	}
+
+	t.Error("Registry.Gather() is missing pompeii_workflow_status")
+}
+
+func TestPush_Unconfigured(t *testing.T) {
+	m := NewRecorder()
+
+	if err := m.Push(nil, "service1", "default"); err != nil {
+		t.Errorf("Push() = %s, want nil", err)
+	}
+
+	if err := m.Push(&config.MetricsConfig{}, "service1", "default"); err != nil {
+		t.Errorf("Push() = %s, want nil", err)
+	}
+}
+
+func TestRecorder_separateRuns(t *testing.T) {
+	first, second := NewRecorder(), NewRecorder()
+	first.CanaryWeight(50)
+
+	if !gathered(t, first)["pompeii_canary_weight"] {
+		t.Errorf("Gather() is missing pompeii_canary_weight")
+	}
+
+	if gathered(t, second)["pompeii_canary_weight"] {
+		t.Errorf("Gather() of another run has pompeii_canary_weight")
+	}
+}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+)
+
+type (
+	// EventType the workflow lifecycle event being reported
+	EventType string
+
+	// Event a single workflow lifecycle event
+	Event struct {
+		Type     EventType      `json:"type"`
+		Service  string         `json:"service"`
+		Workflow string         `json:"workflow"`
+		Step     int            `json:"step"`
+		Action   *config.Action `json:"action,omitempty"`
+		Message  string         `json:"message,omitempty"`
+		Time     time.Time      `json:"time"`
+	}
+
+	// Sink delivers events to a destination
+	Sink interface {
+		Send(event *Event, message string) error
+	}
+
+	// Notifier routes events for a service to its configured sinks
+	Notifier struct {
+		routes []*route
+	}
+
+	route struct {
+		name     string
+		sink     Sink
+		template *template.Template
+		events   map[EventType]bool
+	}
+)
+
+const (
+	WorkflowStarted   EventType = "workflow-started"
+	WorkflowCompleted EventType = "workflow-completed"
+	WorkflowFailed    EventType = "workflow-failed"
+	WorkflowPaused    EventType = "workflow-paused"
+	WorkflowResumed   EventType = "workflow-resumed"
+	WorkflowBlocked   EventType = "workflow-blocked"
+	FreezeOverridden  EventType = "freeze-overridden"
+	StepStarted       EventType = "step-started"
+	StepSucceeded     EventType = "step-succeeded"
+	StepFailed        EventType = "step-failed"
+	RollbackStarted   EventType = "rollback-started"
+	RollbackFinished  EventType = "rollback-finished"
+	RollbackFailed    EventType = "rollback-failed"
+	ApprovalRequested EventType = "approval-requested"
+	ApprovalDecided   EventType = "approval-decided"
+
+	defaultTemplate = `[pompeii] {{ .Service }} {{ .Workflow }}: {{ .Type }}{{ if .Action }} step {{ .Step }} {{ .Action.Type }} {{ .Action.Target }}{{ end }}{{ if .Message }} - {{ .Message }}{{ end }}`
+)
+
+// Events every event type a notifier may subscribe to
+var Events = []EventType{
+	WorkflowStarted,
+	WorkflowCompleted,
+	WorkflowFailed,
+	WorkflowPaused,
+	WorkflowResumed,
+	WorkflowBlocked,
+	FreezeOverridden,
+	StepStarted,
+	StepSucceeded,
+	StepFailed,
+	RollbackStarted,
+	RollbackFinished,
+	RollbackFailed,
+	ApprovalRequested,
+	ApprovalDecided,
+}
+
+// Validate builds every notifier the config defines and routes to, so a mistake such as a misspelled event is
+// reported when the config loads rather than silently dropping notifications
+func Validate(cfg *config.Config) error {
+	names := []string{}
+	for name := range cfg.Notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if _, err := NewForNotifiers(cfg, names); err != nil {
+		return err
+	}
+
+	services := []string{}
+	for service := range cfg.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	for _, service := range services {
+		if cfg.Services[service] == nil {
+			continue
+		}
+
+		if _, err := New(cfg, service); err != nil {
+			return fmt.Errorf("service %s: %s", service, err)
+		}
+
+		if approval := cfg.Services[service].Approval; approval != nil {
+			if _, err := NewForNotifiers(cfg, approval.Notifiers); err != nil {
+				return fmt.Errorf("service %s approval: %s", service, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// New builds a notifier for the sinks the service routes its events to
+func New(cfg *config.Config, service string) (*Notifier, error) {
+	return NewForNotifiers(cfg, cfg.GetNotifiers(service))
+}
+
+// NewForNotifiers builds a notifier for the named sinks
+func NewForNotifiers(cfg *config.Config, names []string) (*Notifier, error) {
+	notifier := &Notifier{}
+
+	for _, name := range names {
+		sinkConfig, ok := cfg.Notifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined notifier: %s", name)
+		}
+
+		sink, err := newSink(sinkConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %s", name, err)
+		}
+
+		text := sinkConfig.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: invalid template: %s", name, err)
+		}
+
+		r := &route{
+			name:     name,
+			sink:     sink,
+			template: tmpl,
+		}
+
+		if len(sinkConfig.Events) > 0 {
+			r.events = map[EventType]bool{}
+			for _, event := range sinkConfig.Events {
+				if err := checkEvent(EventType(event)); err != nil {
+					return nil, fmt.Errorf("notifier %s: %s", name, err)
+				}
+				r.events[EventType(event)] = true
+			}
+		}
+
+		notifier.routes = append(notifier.routes, r)
+	}
+
+	return notifier, nil
+}
+
+func checkEvent(event EventType) error {
+	valid := []string{}
+	for _, known := range Events {
+		if event == known {
+			return nil
+		}
+		valid = append(valid, string(known))
+	}
+
+	return fmt.Errorf("unknown event %q, valid events: %s", event, strings.Join(valid, ", "))
+}
+
+func newSink(sinkConfig *config.NotifierConfig) (Sink, error) {
+	switch sinkConfig.Type {
+	case "slack":
+		if sinkConfig.URL == "" {
+			return nil, fmt.Errorf("url is required")
+		}
+		return &SlackSink{URL: sinkConfig.URL}, nil
+	case "webhook":
+		if sinkConfig.URL == "" {
+			return nil, fmt.Errorf("url is required")
+		}
+		return &WebhookSink{URL: sinkConfig.URL, Secret: sinkConfig.Secret, Headers: sinkConfig.Headers}, nil
+	case "log":
+		return &LogSink{}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type: %s", sinkConfig.Type)
+	}
+}
+
+// Notify delivers the event to every sink subscribed to it, delivery failures are logged and never fail the workflow
+func (n *Notifier) Notify(event *Event) {
+	if n == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	for _, r := range n.routes {
+		if r.events != nil && !r.events[event.Type] {
+			continue
+		}
+
+		message := &bytes.Buffer{}
+		if err := r.template.Execute(message, event); err != nil {
+			logging.Errorf("[notify.Notify] Failed to render template for notifier: %s err: %s", r.name, err)
+			continue
+		}
+
+		if err := r.sink.Send(event, message.String()); err != nil {
+			logging.Errorf("[notify.Notify] Failed to notify: %s err: %s", r.name, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+func newServer(t *testing.T) (*httptest.Server, chan *request) {
+	requests := make(chan *request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests <- &request{header: r.Header, body: body}
+	}))
+
+	return server, requests
+}
+
+func TestNotifier_Slack(t *testing.T) {
+	server, requests := newServer(t)
+	defer server.Close()
+
+	notifier, err := New(&config.Config{
+		Services: map[string]*config.ServiceConfig{
+			"service1": {},
+		},
+		Notifiers: map[string]*config.NotifierConfig{
+			"slack": {
+				Type:     "slack",
+				URL:      server.URL,
+				Template: "{{ .Service }} {{ .Type }}",
+				Events:   []string{string(WorkflowCompleted)},
+			},
+		},
+	}, "service1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Notify(&Event{Type: WorkflowStarted, Service: "service1"})
+	notifier.Notify(&Event{Type: WorkflowCompleted, Service: "service1"})
+
+	if len(requests) != 1 {
+		t.Fatalf("Notify() sent %d requests, want 1", len(requests))
+	}
+
+	got := map[string]string{}
+	if err := json.Unmarshal((<-requests).body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["text"] != "service1 workflow-completed" {
+		t.Errorf("Notify() text = %s, want %s", got["text"], "service1 workflow-completed")
+	}
+}
+
+func TestNotifier_WebhookSignature(t *testing.T) {
+	server, requests := newServer(t)
+	defer server.Close()
+
+	notifier, err := New(&config.Config{
+		Services: map[string]*config.ServiceConfig{
+			"service1": {
+				Notify: []string{"hook"},
+			},
+		},
+		Notifiers: map[string]*config.NotifierConfig{
+			"hook": {
+				Type:   "webhook",
+				URL:    server.URL,
+				Secret: "secret",
+			},
+			"unused": {
+				Type: "slack",
+				URL:  "http://127.0.0.1:1",
+			},
+		},
+	}, "service1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Notify(&Event{Type: StepFailed, Service: "service1", Step: 2})
+
+	if len(requests) != 1 {
+		t.Fatalf("Notify() sent %d requests, want 1", len(requests))
+	}
+
+	req := <-requests
+	if want := "sha256=" + Sign("secret", req.body); req.header.Get(SignatureHeader) != want {
+		t.Errorf("Notify() signature = %s, want %s", req.header.Get(SignatureHeader), want)
+	}
+
+	got := &Event{}
+	if err := json.Unmarshal(req.body, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Type != StepFailed || got.Step != 2 {
+		t.Errorf("Notify() event = %+v", got)
+	}
+}
+
+func TestNew_UnknownNotifier(t *testing.T) {
+	_, err := New(&config.Config{
+		Services: map[string]*config.ServiceConfig{
+			"service1": {
+				Notify: []string{"missing"},
+			},
+		},
+	}, "service1")
+	if err == nil {
+		t.Error("New() expected an error for an undefined notifier")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		wantErr string
+	}{
+		{
+			name: "valid",
+			cfg: &config.Config{
+				Notifiers: map[string]*config.NotifierConfig{
+					"hook": {Type: "webhook", URL: "http://localhost", Events: []string{string(StepFailed), string(ApprovalRequested)}},
+				},
+				Services: map[string]*config.ServiceConfig{
+					"service1": {Notify: []string{"hook"}},
+				},
+			},
+		},
+		{
+			name: "unknown_event",
+			cfg: &config.Config{
+				Notifiers: map[string]*config.NotifierConfig{
+					"hook": {Type: "webhook", URL: "http://localhost", Events: []string{"workflow-complete"}},
+				},
+			},
+			wantErr: `notifier hook: unknown event "workflow-complete", valid events: workflow-started, `,
+		},
+		{
+			name: "undefined_service_notifier",
+			cfg: &config.Config{
+				Services: map[string]*config.ServiceConfig{
+					"service1": {Notify: []string{"missing"}},
+				},
+			},
+			wantErr: "service service1: ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chriskuchin/pompeii/logging"
+)
+
+type (
+	// SlackSink posts events to a slack incoming webhook
+	SlackSink struct {
+		URL string
+	}
+
+	// WebhookSink posts events as json, signing the body when a secret is configured
+	WebhookSink struct {
+		URL     string
+		Secret  string
+		Headers map[string]string
+	}
+
+	// LogSink writes events to the log
+	LogSink struct{}
+
+	webhookPayload struct {
+		*Event
+		Text string `json:"text"`
+	}
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the webhook body
+	SignatureHeader = "X-Pompeii-Signature"
+)
+
+var (
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Send implements Sink
+func (s *SlackSink) Send(event *Event, message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+
+	return post(s.URL, body, nil)
+}
+
+// Send implements Sink
+func (s *WebhookSink) Send(event *Event, message string) error {
+	body, err := json.Marshal(&webhookPayload{Event: event, Text: message})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	for key, value := range s.Headers {
+		headers[key] = value
+	}
+
+	if s.Secret != "" {
+		headers[SignatureHeader] = "sha256=" + Sign(s.Secret, body)
+	}
+
+	return post(s.URL, body, headers)
+}
+
+// Send implements Sink
+func (s *LogSink) Send(event *Event, message string) error {
+	logging.Info(message)
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body using secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package release
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/chriskuchin/pompeii/config"
+)
+
+// GetScalingState returns the pools scalable target settings or nil if the pool isn't managed by application auto scaling
+func (c *Client) GetScalingState(service, pool string) (*config.ScalingState, error) {
+	svc := c.autoscalingSvc[service]
+	input := &applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceEcs),
+		ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionEcsServiceDesiredCount),
+		ResourceIds: []*string{
+			aws.String(c.getScalableResourceID(service, pool)),
+		},
+	}
+
+	result, err := svc.DescribeScalableTargets(input)
+	if err != nil {
+		c.logAWSError(service, pool, err)
+		return nil, err
+	}
+
+	c.logFor(service, pool).Debugf("[autoscaling.GetScalingState] %+v", result)
+
+	if len(result.ScalableTargets) == 0 {
+		return nil, nil
+	}
+
+	target := result.ScalableTargets[0]
+	state := &config.ScalingState{
+		MinCapacity: aws.Int64Value(target.MinCapacity),
+		MaxCapacity: aws.Int64Value(target.MaxCapacity),
+	}
+
+	if target.SuspendedState != nil {
+		state.DynamicScalingInSuspended = aws.BoolValue(target.SuspendedState.DynamicScalingInSuspended)
+		state.DynamicScalingOutSuspended = aws.BoolValue(target.SuspendedState.DynamicScalingOutSuspended)
+		state.ScheduledScalingSuspended = aws.BoolValue(target.SuspendedState.ScheduledScalingSuspended)
+	}
+
+	return state, nil
+}
+
+// UpdateScalingState registers the pools scalable target with the given settings
+func (c *Client) UpdateScalingState(service, pool string, state *config.ScalingState) error {
+	svc := c.autoscalingSvc[service]
+	input := &applicationautoscaling.RegisterScalableTargetInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceEcs),
+		ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionEcsServiceDesiredCount),
+		ResourceId:        aws.String(c.getScalableResourceID(service, pool)),
+		MinCapacity:       aws.Int64(state.MinCapacity),
+		MaxCapacity:       aws.Int64(state.MaxCapacity),
+		SuspendedState: &applicationautoscaling.SuspendedState{
+			DynamicScalingInSuspended:  aws.Bool(state.DynamicScalingInSuspended),
+			DynamicScalingOutSuspended: aws.Bool(state.DynamicScalingOutSuspended),
+			ScheduledScalingSuspended:  aws.Bool(state.ScheduledScalingSuspended),
+		},
+	}
+
+	c.logFor(service, pool).Infof("[autoscaling.UpdateScalingState] Updating scaling of service: %s pool: %s state: %+v", service, pool, state)
+
+	result, err := svc.RegisterScalableTarget(input)
+	if err != nil {
+		c.logAWSError(service, pool, err)
+		return err
+	}
+
+	c.logFor(service, pool).Debugf("[autoscaling.UpdateScalingState] %+v", result)
+	return nil
+}
+
+func (c *Client) getScalableResourceID(service, pool string) string {
+	return fmt.Sprintf("service/%s/%s", c.Config.GetClusterName(service), c.Config.GetECSServiceName(service, pool))
+}
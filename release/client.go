@@ -2,27 +2,46 @@ package release
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+	"github.com/chriskuchin/pompeii/metrics"
 )
 
 type (
 	// Client test
 	Client struct {
-		Config   *config.Config
-		elbv2Svc map[string]*elbv2.ELBV2
-		ecsSvc   map[string]*ecs.ECS
+		Config *config.Config
+		// Logger carries the fields of the run the client works for
+		Logger *logging.Entry
+		// Metrics records the api calls of the run the client works for, nil outside of a run
+		Metrics *metrics.Recorder
+
+		elbv2Svc       map[string]elbv2iface.ELBV2API
+		ecsSvc         map[string]ecsiface.ECSAPI
+		autoscalingSvc map[string]*applicationautoscaling.ApplicationAutoScaling
+
+		// arnCache resolved arns keyed by region and the name or selector they were resolved from
+		arnCache map[string]string
 	}
 )
 
 // NewClient returns an awsClient
 func NewClient(config *config.Config) *Client {
 	client := &Client{
-		Config:   config,
-		elbv2Svc: map[string]*elbv2.ELBV2{},
-		ecsSvc:   map[string]*ecs.ECS{},
+		Config:         config,
+		Logger:         logging.WithFields(logging.Fields{}),
+		elbv2Svc:       map[string]elbv2iface.ELBV2API{},
+		ecsSvc:         map[string]ecsiface.ECSAPI{},
+		autoscalingSvc: map[string]*applicationautoscaling.ApplicationAutoScaling{},
+		arnCache:       map[string]string{},
 	}
 
 	for service := range config.Services {
@@ -30,9 +49,68 @@ func NewClient(config *config.Config) *Client {
 			Region: aws.String(config.GetRegion(service)),
 		})
 
+		client.recordRequests(session)
+		client.logRequests(service, session)
+
 		client.elbv2Svc[service] = elbv2.New(session)
 		client.ecsSvc[service] = ecs.New(session)
+		client.autoscalingSvc[service] = applicationautoscaling.New(session)
 	}
 
 	return client
 }
+
+// SetLogger replaces the logger whose fields annotate the client logs
+func (c *Client) SetLogger(logger *logging.Entry) {
+	c.Logger = logger
+}
+
+// logRequests logs every api call made with the session along with its request id
+// recordRequests records every api call made with the session in the metrics of the clients run
+func (c *Client) recordRequests(sess *session.Session) {
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "pompeii.metrics",
+		Fn: func(r *request.Request) {
+			if c.Metrics != nil {
+				c.Metrics.RequestCompleted(r)
+			}
+		},
+	})
+}
+
+func (c *Client) logRequests(service string, sess *session.Session) {
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "pompeii.logging",
+		Fn: func(r *request.Request) {
+			c.logFor(service, "").WithFields(logging.Fields{
+				logging.Operation: r.Operation.Name,
+				logging.RequestID: r.RequestID,
+			}).Debug("aws request")
+		},
+	})
+}
+
+// logFor returns the clients logger annotated with the service and pool
+func (c *Client) logFor(service, pool string) *logging.Entry {
+	fields := logging.Fields{logging.Service: service}
+	if pool != "" {
+		fields[logging.Pool] = pool
+	}
+
+	return c.Logger.WithFields(fields)
+}
+
+// logAWSError logs a failed api call with its error code and request id
+func (c *Client) logAWSError(service, pool string, err error) {
+	logger := c.logFor(service, pool)
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		logger = logger.WithField(logging.RequestID, reqErr.RequestID())
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		logger.WithField("aws_error_code", aerr.Code()).Error(aerr.Message())
+		return
+	}
+
+	logger.Error(err)
+}
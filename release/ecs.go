@@ -2,18 +2,21 @@ package release
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/chriskuchin/pompeii/config"
-	"github.com/prometheus/common/log"
+	"github.com/chriskuchin/pompeii/logging"
 )
 
 func (c *Client) GetCurrentServiceState(service, pool string) *config.ServiceState {
 	info := c.GetCurrentServiceInfo(service, pool)
+	if info == nil {
+		return nil
+	}
 
 	return &config.ServiceState{
 		TaskDef: *info.TaskDefinition,
@@ -32,102 +35,145 @@ func (c *Client) GetCurrentServiceInfo(service, pool string) *ecs.Service {
 
 	result, err := svc.DescribeServices(input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ecs.ErrCodeServerException:
-				fmt.Println(ecs.ErrCodeServerException, aerr.Error())
-			case ecs.ErrCodeClientException:
-				fmt.Println(ecs.ErrCodeClientException, aerr.Error())
-			case ecs.ErrCodeInvalidParameterException:
-				fmt.Println(ecs.ErrCodeInvalidParameterException, aerr.Error())
-			case ecs.ErrCodeClusterNotFoundException:
-				fmt.Println(ecs.ErrCodeClusterNotFoundException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+		c.logAWSError(service, pool, err)
 		return nil
 	}
 
-	log.Debugf("[ecs.GetCurrentServiceInfo] %+v", result)
+	c.logFor(service, pool).Debugf("[ecs.GetCurrentServiceInfo] %+v", result)
 
 	return result.Services[0]
 }
 
-func (c *Client) UpdateService(service, pool string, state *config.ServiceState) {
+// UpdateService updates the pools task definition and desired count, an empty TaskDef keeps the current task definition
+func (c *Client) UpdateService(service, pool string, state *config.ServiceState) error {
 	svc := c.ecsSvc[service]
 	input := &ecs.UpdateServiceInput{
-		Service:        aws.String(c.Config.GetECSServiceName(service, pool)),
-		TaskDefinition: aws.String(state.TaskDef),
-		Cluster:        aws.String(c.Config.Services[service].ClusterARN),
-		DesiredCount:   aws.Int64(state.Count),
+		Service:      aws.String(c.Config.GetECSServiceName(service, pool)),
+		Cluster:      aws.String(c.Config.Services[service].ClusterARN),
+		DesiredCount: aws.Int64(state.Count),
 	}
 
-	log.Infof("[ecs.UpdateService] Updating service: %s state: %+v input: %+v", service, state, input)
+	if state.TaskDef != "" {
+		input.TaskDefinition = aws.String(state.TaskDef)
+	}
+
+	c.logFor(service, pool).Infof("[ecs.UpdateService] Updating service: %s state: %+v input: %+v", service, state, input)
 
 	result, err := svc.UpdateService(input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ecs.ErrCodeServerException:
-				fmt.Println(ecs.ErrCodeServerException, aerr.Error())
-			case ecs.ErrCodeClientException:
-				fmt.Println(ecs.ErrCodeClientException, aerr.Error())
-			case ecs.ErrCodeInvalidParameterException:
-				fmt.Println(ecs.ErrCodeInvalidParameterException, aerr.Error())
-			case ecs.ErrCodeClusterNotFoundException:
-				fmt.Println(ecs.ErrCodeClusterNotFoundException, aerr.Error())
-			case ecs.ErrCodeServiceNotFoundException:
-				fmt.Println(ecs.ErrCodeServiceNotFoundException, aerr.Error())
-			case ecs.ErrCodeServiceNotActiveException:
-				fmt.Println(ecs.ErrCodeServiceNotActiveException, aerr.Error())
-			case ecs.ErrCodePlatformUnknownException:
-				fmt.Println(ecs.ErrCodePlatformUnknownException, aerr.Error())
-			case ecs.ErrCodePlatformTaskDefinitionIncompatibilityException:
-				fmt.Println(ecs.ErrCodePlatformTaskDefinitionIncompatibilityException, aerr.Error())
-			case ecs.ErrCodeAccessDeniedException:
-				fmt.Println(ecs.ErrCodeAccessDeniedException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
-		return
+		c.logAWSError(service, pool, err)
+		return err
 	}
 
-	log.Debugf("[ecs.UpdateService] %+v", result)
+	c.logFor(service, pool).Debugf("[ecs.UpdateService] %+v", result)
+	return nil
 }
 
+// RunTask launches the given task definition using the services validation task settings
 func (c *Client) RunTask(service, task, container string, command []string) (string, error) {
 	input := &ecs.RunTaskInput{
 		Cluster:        aws.String(c.Config.Services[service].ClusterARN),
 		TaskDefinition: aws.String(task),
 	}
 
-	if len(command) > 0 {
-		commandOverride := []*string{}
-		for _, piece := range command {
-			commandOverride = append(commandOverride, aws.String(piece))
+	taskConfig := c.Config.GetValidationTaskConfig(service)
+	if err := applyTaskConfig(input, taskConfig); err != nil {
+		return "", err
+	}
+
+	overrides, err := buildTaskOverride(taskConfig, container, command)
+	if err != nil {
+		return "", err
+	}
+	input.Overrides = overrides
+
+	return c.runTask(service, input)
+}
+
+func applyTaskConfig(input *ecs.RunTaskInput, taskConfig *config.TaskConfig) error {
+	if taskConfig.LaunchType != "" && len(taskConfig.CapacityProviderStrategy) > 0 {
+		return fmt.Errorf("launch-type and capacity-provider-strategy are mutually exclusive")
+	}
+
+	if taskConfig.LaunchType != "" {
+		input.LaunchType = aws.String(strings.ToUpper(taskConfig.LaunchType))
+	}
+
+	for _, provider := range taskConfig.CapacityProviderStrategy {
+		input.CapacityProviderStrategy = append(input.CapacityProviderStrategy, &ecs.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(provider.CapacityProvider),
+			Base:             aws.Int64(provider.Base),
+			Weight:           aws.Int64(provider.Weight),
+		})
+	}
+
+	if taskConfig.PlatformVersion != "" {
+		input.PlatformVersion = aws.String(taskConfig.PlatformVersion)
+	}
+
+	if len(taskConfig.Subnets) > 0 {
+		assignPublicIP := ecs.AssignPublicIpDisabled
+		if aws.BoolValue(taskConfig.AssignPublicIP) {
+			assignPublicIP = ecs.AssignPublicIpEnabled
 		}
 
-		input.Overrides = &ecs.TaskOverride{
-			ContainerOverrides: []*ecs.ContainerOverride{
-				{
-					Name:    aws.String(container),
-					Command: commandOverride,
-				},
+		input.NetworkConfiguration = &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				Subnets:        aws.StringSlice(taskConfig.Subnets),
+				SecurityGroups: aws.StringSlice(taskConfig.SecurityGroups),
+				AssignPublicIp: aws.String(assignPublicIP),
 			},
 		}
+	} else if len(taskConfig.SecurityGroups) > 0 || aws.BoolValue(taskConfig.AssignPublicIP) {
+		return fmt.Errorf("subnets are required when security-groups or assign-public-ip are set")
 	}
 
-	return c.runTask(service, input)
+	return nil
+}
+
+func buildTaskOverride(taskConfig *config.TaskConfig, container string, command []string) (*ecs.TaskOverride, error) {
+	if len(command) == 0 && len(taskConfig.Environment) == 0 && taskConfig.TaskRoleARN == "" {
+		return nil, nil
+	}
+
+	overrides := &ecs.TaskOverride{}
+	if taskConfig.TaskRoleARN != "" {
+		overrides.TaskRoleArn = aws.String(taskConfig.TaskRoleARN)
+	}
+
+	if len(command) == 0 && len(taskConfig.Environment) == 0 {
+		return overrides, nil
+	}
+
+	if container == "" {
+		return nil, fmt.Errorf("validation-task-container is required to override the command or environment")
+	}
+
+	containerOverride := &ecs.ContainerOverride{
+		Name: aws.String(container),
+	}
+
+	if len(command) > 0 {
+		containerOverride.Command = aws.StringSlice(command)
+	}
+
+	// sort the keys so the request is stable between runs
+	keys := make([]string, 0, len(taskConfig.Environment))
+	for key := range taskConfig.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		containerOverride.Environment = append(containerOverride.Environment, &ecs.KeyValuePair{
+			Name:  aws.String(key),
+			Value: aws.String(taskConfig.Environment[key]),
+		})
+	}
+
+	overrides.ContainerOverrides = []*ecs.ContainerOverride{containerOverride}
+
+	return overrides, nil
 }
 
 func (c *Client) runTask(service string, taskInput *ecs.RunTaskInput) (string, error) {
@@ -135,38 +181,11 @@ func (c *Client) runTask(service string, taskInput *ecs.RunTaskInput) (string, e
 
 	result, err := svc.RunTask(taskInput)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ecs.ErrCodeServerException:
-				fmt.Println(ecs.ErrCodeServerException, aerr.Error())
-			case ecs.ErrCodeClientException:
-				fmt.Println(ecs.ErrCodeClientException, aerr.Error())
-			case ecs.ErrCodeInvalidParameterException:
-				fmt.Println(ecs.ErrCodeInvalidParameterException, aerr.Error())
-			case ecs.ErrCodeClusterNotFoundException:
-				fmt.Println(ecs.ErrCodeClusterNotFoundException, aerr.Error())
-			case ecs.ErrCodeUnsupportedFeatureException:
-				fmt.Println(ecs.ErrCodeUnsupportedFeatureException, aerr.Error())
-			case ecs.ErrCodePlatformUnknownException:
-				fmt.Println(ecs.ErrCodePlatformUnknownException, aerr.Error())
-			case ecs.ErrCodePlatformTaskDefinitionIncompatibilityException:
-				fmt.Println(ecs.ErrCodePlatformTaskDefinitionIncompatibilityException, aerr.Error())
-			case ecs.ErrCodeAccessDeniedException:
-				fmt.Println(ecs.ErrCodeAccessDeniedException, aerr.Error())
-			case ecs.ErrCodeBlockedException:
-				fmt.Println(ecs.ErrCodeBlockedException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+		c.logAWSError(service, "", err)
 		return "", err
 	}
 
-	log.Debugf("[ecs.runTask] %+v", result)
+	c.logFor(service, "").Debugf("[ecs.runTask] %+v", result)
 
 	return *result.Tasks[0].TaskArn, nil
 }
@@ -183,28 +202,11 @@ func (c *Client) DescribeTask(service, taskARN string) (*config.TaskState, error
 
 	result, err := svc.DescribeTasks(input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ecs.ErrCodeServerException:
-				fmt.Println(ecs.ErrCodeServerException, aerr.Error())
-			case ecs.ErrCodeClientException:
-				fmt.Println(ecs.ErrCodeClientException, aerr.Error())
-			case ecs.ErrCodeInvalidParameterException:
-				fmt.Println(ecs.ErrCodeInvalidParameterException, aerr.Error())
-			case ecs.ErrCodeClusterNotFoundException:
-				fmt.Println(ecs.ErrCodeClusterNotFoundException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+		c.logAWSError(service, "", err)
 		return nil, err
 	}
 
-	log.Debugf("[ecs.DescribeTask] ", result)
+	c.logFor(service, "").Debugf("[ecs.DescribeTask] %+v", result)
 
 	return c.calcuateTaskState(result), nil
 }
@@ -227,7 +229,7 @@ func (c *Client) calcuateTaskState(taskInfo *ecs.DescribeTasksOutput) *config.Ta
 		state.Running = false
 
 		if len(taskInfo.Tasks[0].Containers) == 1 {
-			log.Info(*&taskInfo.Tasks[0].Containers)
+			logging.Debugf("[ecs.calcuateTaskState] %+v", taskInfo.Tasks[0].Containers)
 			if *taskInfo.Tasks[0].Containers[0].ExitCode == 0 {
 				state.Failed = false
 			} else {
@@ -240,8 +242,13 @@ func (c *Client) calcuateTaskState(taskInfo *ecs.DescribeTasksOutput) *config.Ta
 
 // StartAndMonitorTask launch a task and monitor it's runtime and return true if it failed
 func (c *Client) StartAndMonitorTask(service, task, container string, command []string) bool {
-	taskARN, _ := c.RunTask(service, task, container, command)
-	time.Sleep(30 * time.Second)
+	taskARN, err := c.RunTask(service, task, container, command)
+	if err != nil {
+		c.logFor(service, "").Errorf("[ecs.StartAndMonitorTask] Failed to start task: %s", err)
+		return true
+	}
+
+	time.Sleep(c.Config.GetPollInterval(service))
 	return c.monitorTaskRun(service, taskARN)
 }
 
@@ -249,12 +256,12 @@ func (c *Client) monitorTaskRun(service, taskARN string) bool {
 	state, _ := c.DescribeTask(service, taskARN)
 
 	for state != nil && state.Running {
-		time.Sleep(1 * time.Minute)
+		time.Sleep(c.Config.GetPollInterval(service))
 
 		state, _ = c.DescribeTask(service, taskARN)
 	}
 
-	log.Debugf("[ecs.StartAndMonitorTask] %+v", state)
+	c.logFor(service, "").Debugf("[ecs.StartAndMonitorTask] %+v", state)
 
 	return state != nil && state.Failed
 }
@@ -277,11 +284,11 @@ func (c *Client) RollbackDeployment(service, pool string) {
 	}
 
 	if previousTaskDef.TaskDef == "" || newTaskDef.TaskDef == "" {
-		log.Errorf("Failed to locate the previous or new version. previousTaskDef: %+v newTaskDef: %+v", previousTaskDef, newTaskDef)
+		c.logFor(service, pool).Errorf("Failed to locate the previous or new version. previousTaskDef: %+v newTaskDef: %+v", previousTaskDef, newTaskDef)
 		return
 	}
 
-	log.Debugf("Rolling back from %+v to %+v", newTaskDef, previousTaskDef)
+	c.logFor(service, pool).Debugf("Rolling back from %+v to %+v", newTaskDef, previousTaskDef)
 
 	c.UpdateService(service, pool, previousTaskDef)
 }
@@ -298,48 +305,206 @@ func getActiveDeployment(info *ecs.Service) *ecs.Deployment {
 
 // MonitorServiceDeployment waits for a deployment to complete before returing true if it succeeds and false if it doesn't
 func (c *Client) MonitorServiceDeployment(service, pool string) bool {
-	log.Infof("[ecs.MonitorServiceDeployment] Monitoring deployment of service: %s to the %s pool", service, pool)
+	c.logFor(service, pool).Infof("[ecs.MonitorServiceDeployment] Monitoring deployment of service: %s to the %s pool", service, pool)
+	seenEvents := map[string]bool{}
 	for {
 		info := c.GetCurrentServiceInfo(service, pool)
-		log.Debugf("[ecs.MonitorServiceDeployment] %#v\n", info.Deployments)
-
-		if len(info.Deployments) == 1 {
-			return true
+		if info == nil {
+			c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Failed to describe service: %s pool: %s", service, pool)
+			return false
 		}
+		c.logFor(service, pool).Debugf("[ecs.MonitorServiceDeployment] %#v\n", info.Deployments)
 
 		deployment := getActiveDeployment(info)
 		if deployment == nil {
-			log.Errorf("[ecs.MonitorServiceDeployment] Failed to locate the Primary deployment: %#v", info.Deployments)
+			c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Failed to locate the Primary deployment: %#v", info.Deployments)
+			return false
+		}
+
+		failed := logDeploymentEvents(c.logFor(service, pool), info, deployment, seenEvents)
+
+		switch aws.StringValue(deployment.RolloutState) {
+		case ecs.DeploymentRolloutStateCompleted:
+			return true
+		case ecs.DeploymentRolloutStateFailed:
+			c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Deployment circuit breaker tripped: %s", aws.StringValue(deployment.RolloutStateReason))
+			c.reportStoppedTasks(service, pool, deployment)
+			return false
+		}
+
+		if failed {
+			c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Service events report the deployment failed")
+			c.reportStoppedTasks(service, pool, deployment)
 			return false
 		}
 
-		if time.Now().Sub(*deployment.CreatedAt) > c.Config.Services[service].Timeout {
-			log.Errorf("[ecs.MonitorServiceDeployment] Deployment Timed out: %v", c.Config.Services[service].Timeout)
+		if maxFailed := c.Config.GetMaxFailedTasks(service); aws.Int64Value(deployment.FailedTasks) >= maxFailed {
+			c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Deployment has %d failed tasks, limit: %d", aws.Int64Value(deployment.FailedTasks), maxFailed)
+			c.reportStoppedTasks(service, pool, deployment)
 			return false
 		}
 
-		log.Infof("[ecs.MonitorServiceDeployment] Waiting for deployment to complete, service: %s pool: %s sleeping", service, pool)
-		time.Sleep(30 * time.Second)
+		if len(info.Deployments) == 1 && aws.Int64Value(deployment.RunningCount) == aws.Int64Value(deployment.DesiredCount) {
+			return true
+		}
+
+		if time.Now().Sub(*deployment.CreatedAt) > c.Config.GetTimeout(service) {
+			c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Deployment Timed out: %v", c.Config.GetTimeout(service))
+			c.reportStoppedTasks(service, pool, deployment)
+			return false
+		}
+
+		c.logFor(service, pool).Infof("[ecs.MonitorServiceDeployment] Waiting for deployment to complete, service: %s pool: %s running: %d desired: %d failed: %d sleeping",
+			service, pool, aws.Int64Value(deployment.RunningCount), aws.Int64Value(deployment.DesiredCount), aws.Int64Value(deployment.FailedTasks))
+		time.Sleep(c.Config.GetPollInterval(service))
+	}
+}
+
+// logDeploymentEvents logs the service events raised since the deployment started and reports whether any of them mark the deployment as failed
+func logDeploymentEvents(logger *logging.Entry, info *ecs.Service, deployment *ecs.Deployment, seen map[string]bool) bool {
+	failed := false
+	// events are returned newest first
+	for i := len(info.Events) - 1; i >= 0; i-- {
+		event := info.Events[i]
+		if event.CreatedAt == nil || event.CreatedAt.Before(*deployment.CreatedAt) || seen[aws.StringValue(event.Id)] {
+			continue
+		}
+		seen[aws.StringValue(event.Id)] = true
+
+		message := aws.StringValue(event.Message)
+		logger.Infof("[ecs.MonitorServiceDeployment] Service event: %s", message)
+
+		if strings.Contains(message, aws.StringValue(deployment.Id)) && strings.Contains(message, "deployment failed") {
+			failed = true
+		}
+	}
+
+	return failed
+}
+
+// reportStoppedTasks logs why the tasks started by a deployment stopped
+func (c *Client) reportStoppedTasks(service, pool string, deployment *ecs.Deployment) {
+	for _, reason := range c.GetStoppedTaskReasons(service, pool, deployment) {
+		c.logFor(service, pool).Errorf("[ecs.MonitorServiceDeployment] Stopped task: %s", reason)
+	}
+}
+
+// GetStoppedTaskReasons returns a description of why each stopped task belonging to the deployment stopped
+func (c *Client) GetStoppedTaskReasons(service, pool string, deployment *ecs.Deployment) []string {
+	svc := c.ecsSvc[service]
+	listInput := &ecs.ListTasksInput{
+		Cluster:       aws.String(c.Config.Services[service].ClusterARN),
+		ServiceName:   aws.String(c.Config.GetECSServiceName(service, pool)),
+		DesiredStatus: aws.String(ecs.DesiredStatusStopped),
+	}
+
+	listResult, err := svc.ListTasks(listInput)
+	if err != nil {
+		c.logAWSError(service, pool, err)
+		return nil
+	}
+
+	if len(listResult.TaskArns) == 0 {
+		return nil
+	}
+
+	describeInput := &ecs.DescribeTasksInput{
+		Cluster: listInput.Cluster,
+		Tasks:   listResult.TaskArns,
+	}
+
+	describeResult, err := svc.DescribeTasks(describeInput)
+	if err != nil {
+		c.logAWSError(service, pool, err)
+		return nil
+	}
+
+	reasons := []string{}
+	for _, task := range describeResult.Tasks {
+		if aws.StringValue(task.StartedBy) != aws.StringValue(deployment.Id) && aws.StringValue(task.TaskDefinitionArn) != aws.StringValue(deployment.TaskDefinition) {
+			continue
+		}
+
+		reason := fmt.Sprintf("%s: %s", aws.StringValue(task.TaskArn), aws.StringValue(task.StoppedReason))
+		for _, container := range task.Containers {
+			if container.ExitCode != nil {
+				reason = fmt.Sprintf("%s [%s exit code: %d %s]", reason, aws.StringValue(container.Name), *container.ExitCode, aws.StringValue(container.Reason))
+			} else if container.Reason != nil {
+				reason = fmt.Sprintf("%s [%s %s]", reason, aws.StringValue(container.Name), *container.Reason)
+			}
+		}
+
+		reasons = append(reasons, reason)
 	}
+
+	return reasons
 }
 
 // Deploy deploys the given service and waits for the deployment to complete
 func (c *Client) Deploy(service, pool string, state *config.ServiceState) bool {
-	log.Infof("[ecs.Deploy] Starting Deployment of %s to %s: Desired State: %+v", service, pool, state)
+	c.logFor(service, pool).Infof("[ecs.Deploy] Starting Deployment of %s to %s: Desired State: %+v", service, pool, state)
 	rollbackState := c.GetCurrentServiceState(service, pool)
-	log.Infof("[ecs.Deploy] Calculated rollback state: %+v", rollbackState)
+	if rollbackState == nil {
+		c.logFor(service, pool).Errorf("[ecs.Deploy] Refusing to deploy without a state to roll back to")
+		return false
+	}
+	c.logFor(service, pool).Infof("[ecs.Deploy] Calculated rollback state: %+v", rollbackState)
 
-	c.UpdateService(service, pool, state)
-	log.Infof("[ecs.Deploy] Service update started: %s %s %+v", service, pool, state)
+	if err := c.UpdateService(service, pool, state); err != nil {
+		c.logFor(service, pool).Errorf("[ecs.Deploy] Failed to update service: %s", err)
+		return false
+	}
+	c.logFor(service, pool).Infof("[ecs.Deploy] Service update started: %s %s %+v", service, pool, state)
 
 	result := c.MonitorServiceDeployment(service, pool)
 
 	if result == false {
-		log.Infof("[ecs.Deploy] Deployment Failed, rolling back update: %+v", rollbackState)
+		c.logFor(service, pool).Infof("[ecs.Deploy] Deployment Failed, rolling back update: %+v", rollbackState)
 		c.UpdateService(service, pool, rollbackState)
 		return false
 	}
 
-	log.Infof("[ecs.Deploy] Succesfully deployed: %+v", c.GetCurrentServiceState(service, pool))
+	c.logFor(service, pool).Infof("[ecs.Deploy] Succesfully deployed: %+v", c.GetCurrentServiceState(service, pool))
 	return true
 }
+
+// Scale changes the desired count of a pool without changing its task definition and waits for the running count to match
+func (c *Client) Scale(service, pool string, count int64) bool {
+	c.logFor(service, pool).Infof("[ecs.Scale] Scaling service: %s pool: %s to %d tasks", service, pool, count)
+	if err := c.UpdateService(service, pool, &config.ServiceState{Count: count}); err != nil {
+		return false
+	}
+
+	return c.WaitForServiceStable(service, pool)
+}
+
+// WaitForServiceStable waits until the pool has a single deployment running its desired number of tasks
+func (c *Client) WaitForServiceStable(service, pool string) bool {
+	started := time.Now()
+	for {
+		info := c.GetCurrentServiceInfo(service, pool)
+		if info == nil {
+			return false
+		}
+
+		deployment := getActiveDeployment(info)
+		if deployment == nil {
+			c.logFor(service, pool).Errorf("[ecs.WaitForServiceStable] Failed to locate the Primary deployment: %#v", info.Deployments)
+			return false
+		}
+
+		if len(info.Deployments) == 1 && aws.Int64Value(info.RunningCount) == aws.Int64Value(info.DesiredCount) {
+			return true
+		}
+
+		if time.Now().Sub(started) > c.Config.GetTimeout(service) {
+			c.logFor(service, pool).Errorf("[ecs.WaitForServiceStable] Timed out waiting for service: %s pool: %s running: %d desired: %d",
+				service, pool, aws.Int64Value(info.RunningCount), aws.Int64Value(info.DesiredCount))
+			return false
+		}
+
+		c.logFor(service, pool).Infof("[ecs.WaitForServiceStable] Waiting for service: %s pool: %s running: %d desired: %d sleeping",
+			service, pool, aws.Int64Value(info.RunningCount), aws.Int64Value(info.DesiredCount))
+		time.Sleep(c.Config.GetPollInterval(service))
+	}
+}
@@ -0,0 +1,375 @@
+package release
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+)
+
+// fakeECS returns the services in order from successive DescribeServices calls, repeating the last one
+type fakeECS struct {
+	ecsiface.ECSAPI
+	services    []*ecs.Service
+	described   int
+	describeErr error
+	tasks       []*ecs.Task
+	updated     []*ecs.UpdateServiceInput
+	ran         []*ecs.RunTaskInput
+}
+
+func (f *fakeECS) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+
+	i := f.described
+	if i >= len(f.services) {
+		i = len(f.services) - 1
+	}
+	f.described++
+
+	return &ecs.DescribeServicesOutput{Services: []*ecs.Service{f.services[i]}}, nil
+}
+
+func (f *fakeECS) UpdateService(input *ecs.UpdateServiceInput) (*ecs.UpdateServiceOutput, error) {
+	f.updated = append(f.updated, input)
+	return &ecs.UpdateServiceOutput{}, nil
+}
+
+func (f *fakeECS) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	f.ran = append(f.ran, input)
+	return &ecs.RunTaskOutput{Tasks: []*ecs.Task{{TaskArn: aws.String("task-arn")}}}, nil
+}
+
+func (f *fakeECS) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	output := &ecs.ListTasksOutput{}
+	for _, task := range f.tasks {
+		output.TaskArns = append(output.TaskArns, task.TaskArn)
+	}
+	return output, nil
+}
+
+func (f *fakeECS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	return &ecs.DescribeTasksOutput{Tasks: f.tasks}, nil
+}
+
+func newTestClient(svc *fakeECS) *Client {
+	return &Client{
+		Config: &config.Config{
+			Services: map[string]*config.ServiceConfig{
+				"app": {
+					ClusterARN:     "cluster",
+					PollInterval:   time.Millisecond,
+					Timeout:        time.Minute,
+					MaxFailedTasks: 2,
+					Canary:         &config.PoolConfig{Service: "app-canary"},
+					Primary:        &config.PoolConfig{Service: "app"},
+				},
+			},
+		},
+		Logger:   logging.WithFields(logging.Fields{}),
+		ecsSvc:   map[string]ecsiface.ECSAPI{"app": svc},
+		arnCache: map[string]string{},
+	}
+}
+
+func newDeployment(rolloutState string, running, desired, failed int64) *ecs.Deployment {
+	deployment := &ecs.Deployment{
+		Id:             aws.String("ecs-svc/2"),
+		Status:         aws.String("PRIMARY"),
+		TaskDefinition: aws.String("app:2"),
+		CreatedAt:      aws.Time(time.Now()),
+		RunningCount:   aws.Int64(running),
+		DesiredCount:   aws.Int64(desired),
+		FailedTasks:    aws.Int64(failed),
+	}
+	if rolloutState != "" {
+		deployment.RolloutState = aws.String(rolloutState)
+	}
+
+	return deployment
+}
+
+func TestClient_MonitorServiceDeployment(t *testing.T) {
+	previous := &ecs.Deployment{Id: aws.String("ecs-svc/1"), Status: aws.String("ACTIVE")}
+
+	stale := newDeployment(ecs.DeploymentRolloutStateInProgress, 0, 2, 0)
+	stale.CreatedAt = aws.Time(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name     string
+		services []*ecs.Service
+		want     bool
+	}{
+		{
+			name:     "rollout_completed",
+			services: []*ecs.Service{{Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateCompleted, 2, 2, 0)}}},
+			want:     true,
+		},
+		{
+			name:     "rollout_failed",
+			services: []*ecs.Service{{Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateFailed, 0, 2, 1), previous}}},
+			want:     false,
+		},
+		{
+			name: "in_progress_then_completed",
+			services: []*ecs.Service{
+				{Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateInProgress, 0, 2, 0), previous}},
+				{Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateInProgress, 2, 2, 0), previous}},
+				{Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateCompleted, 2, 2, 0)}},
+			},
+			want: true,
+		},
+		{
+			name:     "steady_without_rollout_state",
+			services: []*ecs.Service{{Deployments: []*ecs.Deployment{newDeployment("", 2, 2, 0)}}},
+			want:     true,
+		},
+		{
+			name:     "failed_tasks",
+			services: []*ecs.Service{{Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateInProgress, 0, 2, 2), previous}}},
+			want:     false,
+		},
+		{
+			name: "failed_event",
+			services: []*ecs.Service{{
+				Deployments: []*ecs.Deployment{newDeployment(ecs.DeploymentRolloutStateInProgress, 0, 2, 0), previous},
+				Events: []*ecs.ServiceEvent{{
+					Id:        aws.String("event1"),
+					CreatedAt: aws.Time(time.Now().Add(time.Second)),
+					Message:   aws.String("(service app) (deployment ecs-svc/2) deployment failed: tasks failed to start."),
+				}},
+			}},
+			want: false,
+		},
+		{
+			name:     "no_primary_deployment",
+			services: []*ecs.Service{{Deployments: []*ecs.Deployment{previous}}},
+			want:     false,
+		},
+		{
+			name:     "timed_out",
+			services: []*ecs.Service{{Deployments: []*ecs.Deployment{stale, previous}}},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(&fakeECS{services: tt.services})
+
+			if got := client.MonitorServiceDeployment("app", "primary"); got != tt.want {
+				t.Errorf("MonitorServiceDeployment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_GetStoppedTaskReasons(t *testing.T) {
+	deployment := newDeployment(ecs.DeploymentRolloutStateFailed, 0, 2, 2)
+
+	tests := []struct {
+		name  string
+		tasks []*ecs.Task
+		want  []string
+	}{
+		{
+			name:  "none_stopped",
+			tasks: []*ecs.Task{},
+			want:  nil,
+		},
+		{
+			name: "exit_code",
+			tasks: []*ecs.Task{{
+				TaskArn:       aws.String("task1"),
+				StartedBy:     aws.String("ecs-svc/2"),
+				StoppedReason: aws.String("Essential container in task exited"),
+				Containers: []*ecs.Container{
+					{Name: aws.String("app"), ExitCode: aws.Int64(1), Reason: aws.String("OOM")},
+					{Name: aws.String("sidecar")},
+				},
+			}},
+			want: []string{"task1: Essential container in task exited [app exit code: 1 OOM]"},
+		},
+		{
+			name: "container_reason",
+			tasks: []*ecs.Task{{
+				TaskArn:           aws.String("task2"),
+				TaskDefinitionArn: aws.String("app:2"),
+				StoppedReason:     aws.String("Task failed to start"),
+				Containers:        []*ecs.Container{{Name: aws.String("app"), Reason: aws.String("CannotPullContainerError")}},
+			}},
+			want: []string{"task2: Task failed to start [app CannotPullContainerError]"},
+		},
+		{
+			name: "other_deployment",
+			tasks: []*ecs.Task{{
+				TaskArn:           aws.String("task3"),
+				StartedBy:         aws.String("ecs-svc/1"),
+				TaskDefinitionArn: aws.String("app:1"),
+				StoppedReason:     aws.String("Scaling activity initiated by deployment ecs-svc/2"),
+			}},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(&fakeECS{tasks: tt.tasks})
+
+			if got := client.GetStoppedTaskReasons("app", "primary", deployment); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetStoppedTaskReasons() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Deploy_unreadableState(t *testing.T) {
+	svc := &fakeECS{describeErr: errors.New("throttled")}
+	client := newTestClient(svc)
+
+	if client.Deploy("app", "primary", &config.ServiceState{TaskDef: "app:2", Count: 2}) {
+		t.Errorf("Deploy() = true, want false")
+	}
+	if len(svc.updated) != 0 {
+		t.Errorf("Deploy() updated the service %d times without a state to roll back to", len(svc.updated))
+	}
+}
+
+func TestClient_RunTask(t *testing.T) {
+	tests := []struct {
+		name       string
+		taskConfig *config.TaskConfig
+		container  string
+		command    []string
+		want       *ecs.RunTaskInput
+		wantErr    string
+	}{
+		{
+			name: "fargate_awsvpc",
+			taskConfig: &config.TaskConfig{
+				LaunchType:      "fargate",
+				PlatformVersion: "1.4.0",
+				Subnets:         []string{"subnet-a", "subnet-b"},
+				SecurityGroups:  []string{"sg-a"},
+				AssignPublicIP:  aws.Bool(true),
+				Environment:     map[string]string{"STAGE": "canary", "DEBUG": "1"},
+				TaskRoleARN:     "task-role",
+			},
+			container: "app",
+			command:   []string{"./smoke-test", "--fast"},
+			want: &ecs.RunTaskInput{
+				Cluster:         aws.String("cluster"),
+				TaskDefinition:  aws.String("smoke-test:3"),
+				LaunchType:      aws.String(ecs.LaunchTypeFargate),
+				PlatformVersion: aws.String("1.4.0"),
+				NetworkConfiguration: &ecs.NetworkConfiguration{
+					AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+						Subnets:        aws.StringSlice([]string{"subnet-a", "subnet-b"}),
+						SecurityGroups: aws.StringSlice([]string{"sg-a"}),
+						AssignPublicIp: aws.String(ecs.AssignPublicIpEnabled),
+					},
+				},
+				Overrides: &ecs.TaskOverride{
+					TaskRoleArn: aws.String("task-role"),
+					ContainerOverrides: []*ecs.ContainerOverride{{
+						Name:    aws.String("app"),
+						Command: aws.StringSlice([]string{"./smoke-test", "--fast"}),
+						Environment: []*ecs.KeyValuePair{
+							{Name: aws.String("DEBUG"), Value: aws.String("1")},
+							{Name: aws.String("STAGE"), Value: aws.String("canary")},
+						},
+					}},
+				},
+			},
+		},
+		{
+			name:       "ec2_bridge",
+			taskConfig: &config.TaskConfig{LaunchType: "ec2"},
+			want: &ecs.RunTaskInput{
+				Cluster:        aws.String("cluster"),
+				TaskDefinition: aws.String("smoke-test:3"),
+				LaunchType:     aws.String(ecs.LaunchTypeEc2),
+			},
+		},
+		{
+			name: "capacity_provider_private",
+			taskConfig: &config.TaskConfig{
+				CapacityProviderStrategy: []*config.CapacityProviderConfig{{CapacityProvider: "FARGATE_SPOT", Base: 1, Weight: 2}},
+				Subnets:                  []string{"subnet-a"},
+				AssignPublicIP:           aws.Bool(false),
+			},
+			want: &ecs.RunTaskInput{
+				Cluster:        aws.String("cluster"),
+				TaskDefinition: aws.String("smoke-test:3"),
+				CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{{
+					CapacityProvider: aws.String("FARGATE_SPOT"),
+					Base:             aws.Int64(1),
+					Weight:           aws.Int64(2),
+				}},
+				NetworkConfiguration: &ecs.NetworkConfiguration{
+					AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+						Subnets:        aws.StringSlice([]string{"subnet-a"}),
+						SecurityGroups: []*string{},
+						AssignPublicIp: aws.String(ecs.AssignPublicIpDisabled),
+					},
+				},
+			},
+		},
+		{
+			name:       "missing_subnets",
+			taskConfig: &config.TaskConfig{LaunchType: "fargate", SecurityGroups: []string{"sg-a"}},
+			wantErr:    "subnets are required when security-groups or assign-public-ip are set",
+		},
+		{
+			name:       "public_ip_without_subnets",
+			taskConfig: &config.TaskConfig{LaunchType: "fargate", AssignPublicIP: aws.Bool(true)},
+			wantErr:    "subnets are required when security-groups or assign-public-ip are set",
+		},
+		{
+			name: "launch_type_and_capacity_provider",
+			taskConfig: &config.TaskConfig{
+				LaunchType:               "fargate",
+				CapacityProviderStrategy: []*config.CapacityProviderConfig{{CapacityProvider: "FARGATE"}},
+			},
+			wantErr: "launch-type and capacity-provider-strategy are mutually exclusive",
+		},
+		{
+			name:       "command_without_container",
+			taskConfig: &config.TaskConfig{LaunchType: "ec2"},
+			command:    []string{"./smoke-test"},
+			wantErr:    "validation-task-container is required to override the command or environment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeECS{}
+			client := newTestClient(svc)
+			client.Config.Services["app"].ValidationTaskConfig = tt.taskConfig
+
+			taskARN, err := client.RunTask("app", "smoke-test:3", tt.container, tt.command)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("RunTask() error = %v, want %s", err, tt.wantErr)
+				}
+				if len(svc.ran) != 0 {
+					t.Errorf("RunTask() started a task with an invalid config: %v", svc.ran)
+				}
+				return
+			}
+
+			if err != nil || taskARN != "task-arn" {
+				t.Fatalf("RunTask() = %s, %v", taskARN, err)
+			}
+
+			if len(svc.ran) != 1 || !reflect.DeepEqual(svc.ran[0], tt.want) {
+				t.Errorf("RunTask() input = %v, want %v", svc.ran, tt.want)
+			}
+		})
+	}
+}
@@ -4,15 +4,17 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/chriskuchin/pompeii/config"
-	"github.com/prometheus/common/log"
 )
 
-// GetCurrentWeights returns the current weights for the primary and canary groups
+// GetCurrentWeights returns the current weights for the primary and canary groups, or nil when the listener rule can't be read
 func (c *Client) GetCurrentWeights(service string) *config.ServiceWeights {
-	rule := c.getCurrentRule(service)
+	rule, err := c.getCurrentRule(service)
+	if err != nil {
+		return nil
+	}
+
 	weights := &config.ServiceWeights{}
 	for _, action := range rule.Actions {
 		if *action.Type == "forward" {
@@ -29,7 +31,7 @@ func (c *Client) GetCurrentWeights(service string) *config.ServiceWeights {
 	return weights
 }
 
-func (c *Client) getCurrentRule(service string) *elbv2.Rule {
+func (c *Client) getCurrentRule(service string) (*elbv2.Rule, error) {
 	svc := c.elbv2Svc[service]
 	input := &elbv2.DescribeRulesInput{
 		RuleArns: []*string{
@@ -39,32 +41,24 @@ func (c *Client) getCurrentRule(service string) *elbv2.Rule {
 
 	result, err := svc.DescribeRules(input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case elbv2.ErrCodeListenerNotFoundException:
-				fmt.Println(elbv2.ErrCodeListenerNotFoundException, aerr.Error())
-			case elbv2.ErrCodeRuleNotFoundException:
-				fmt.Println(elbv2.ErrCodeRuleNotFoundException, aerr.Error())
-			case elbv2.ErrCodeUnsupportedProtocolException:
-				fmt.Println(elbv2.ErrCodeUnsupportedProtocolException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
-		return nil
+		c.logAWSError(service, "", err)
+		return nil, err
 	}
 
-	return result.Rules[0]
+	if len(result.Rules) == 0 {
+		return nil, fmt.Errorf("listener rule %s not found", c.Config.GetListenerRuleARN(service))
+	}
+
+	return result.Rules[0], nil
 }
 
 // UpdateWeights updates the given services weight settings
 func (c *Client) UpdateWeights(service string, weights *config.ServiceWeights) error {
 	svc := c.elbv2Svc[service]
-	rule := c.getCurrentRule(service)
+	rule, err := c.getCurrentRule(service)
+	if err != nil {
+		return err
+	}
 
 	for _, action := range rule.Actions {
 		if *action.Type == "forward" {
@@ -78,7 +72,7 @@ func (c *Client) UpdateWeights(service string, weights *config.ServiceWeights) e
 		}
 	}
 
-	log.Debug(rule)
+	c.logFor(service, "").Debug(rule)
 
 	input := &elbv2.ModifyRuleInput{
 		Actions: rule.Actions,
@@ -87,41 +81,44 @@ func (c *Client) UpdateWeights(service string, weights *config.ServiceWeights) e
 
 	result, err := svc.ModifyRule(input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case elbv2.ErrCodeTargetGroupAssociationLimitException:
-				fmt.Println(elbv2.ErrCodeTargetGroupAssociationLimitException, aerr.Error())
-			case elbv2.ErrCodeIncompatibleProtocolsException:
-				fmt.Println(elbv2.ErrCodeIncompatibleProtocolsException, aerr.Error())
-			case elbv2.ErrCodeRuleNotFoundException:
-				fmt.Println(elbv2.ErrCodeRuleNotFoundException, aerr.Error())
-			case elbv2.ErrCodeOperationNotPermittedException:
-				fmt.Println(elbv2.ErrCodeOperationNotPermittedException, aerr.Error())
-			case elbv2.ErrCodeTooManyRegistrationsForTargetIdException:
-				fmt.Println(elbv2.ErrCodeTooManyRegistrationsForTargetIdException, aerr.Error())
-			case elbv2.ErrCodeTooManyTargetsException:
-				fmt.Println(elbv2.ErrCodeTooManyTargetsException, aerr.Error())
-			case elbv2.ErrCodeTargetGroupNotFoundException:
-				fmt.Println(elbv2.ErrCodeTargetGroupNotFoundException, aerr.Error())
-			case elbv2.ErrCodeUnsupportedProtocolException:
-				fmt.Println(elbv2.ErrCodeUnsupportedProtocolException, aerr.Error())
-			case elbv2.ErrCodeTooManyActionsException:
-				fmt.Println(elbv2.ErrCodeTooManyActionsException, aerr.Error())
-			case elbv2.ErrCodeInvalidLoadBalancerActionException:
-				fmt.Println(elbv2.ErrCodeInvalidLoadBalancerActionException, aerr.Error())
-			case elbv2.ErrCodeTooManyUniqueTargetGroupsPerLoadBalancerException:
-				fmt.Println(elbv2.ErrCodeTooManyUniqueTargetGroupsPerLoadBalancerException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+		c.logAWSError(service, "", err)
 		return err
 	}
 
-	log.Debug(result)
+	c.logFor(service, "").Debug(result)
 	return nil
 }
+
+// GetTargetHealth summarizes the health of the targets registered with the given pools target group
+func (c *Client) GetTargetHealth(service, pool string) (*config.TargetHealth, error) {
+	svc := c.elbv2Svc[service]
+	input := &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(c.Config.GetTargetGroupARN(service, pool)),
+	}
+
+	result, err := svc.DescribeTargetHealth(input)
+	if err != nil {
+		c.logAWSError(service, pool, err)
+		return nil, err
+	}
+
+	c.logFor(service, pool).Debugf("[elbv2.GetTargetHealth] %+v", result)
+
+	health := &config.TargetHealth{}
+	for _, description := range result.TargetHealthDescriptions {
+		if description.TargetHealth == nil {
+			continue
+		}
+
+		switch aws.StringValue(description.TargetHealth.State) {
+		case elbv2.TargetHealthStateEnumDraining:
+			// draining targets are on their way out and don't count towards capacity
+			continue
+		case elbv2.TargetHealthStateEnumHealthy:
+			health.Healthy++
+		}
+		health.Total++
+	}
+
+	return health, nil
+}
@@ -0,0 +1,129 @@
+package release
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/chriskuchin/pompeii/config"
+)
+
+// fakeELB serves a single forward rule, or describeErr when it is set
+type fakeELB struct {
+	elbv2iface.ELBV2API
+	describeErr error
+	rules       []*elbv2.Rule
+	modified    *elbv2.ModifyRuleInput
+}
+
+func (f *fakeELB) DescribeRules(input *elbv2.DescribeRulesInput) (*elbv2.DescribeRulesOutput, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+
+	return &elbv2.DescribeRulesOutput{Rules: f.rules}, nil
+}
+
+func (f *fakeELB) ModifyRule(input *elbv2.ModifyRuleInput) (*elbv2.ModifyRuleOutput, error) {
+	f.modified = input
+	return &elbv2.ModifyRuleOutput{}, nil
+}
+
+func newForwardRule(primary, canary int64) *elbv2.Rule {
+	return &elbv2.Rule{
+		Actions: []*elbv2.Action{{
+			Type: aws.String("forward"),
+			ForwardConfig: &elbv2.ForwardActionConfig{
+				TargetGroups: []*elbv2.TargetGroupTuple{
+					{TargetGroupArn: aws.String("primary-tg"), Weight: aws.Int64(primary)},
+					{TargetGroupArn: aws.String("canary-tg"), Weight: aws.Int64(canary)},
+				},
+			},
+		}},
+	}
+}
+
+func newTestELBClient(svc *fakeELB) *Client {
+	client := newTestClient(&fakeECS{})
+	client.Config.Services["app"].ListenerARN = "rule"
+	client.Config.Services["app"].Primary.TargetGroupARN = "primary-tg"
+	client.Config.Services["app"].Canary.TargetGroupARN = "canary-tg"
+	client.elbv2Svc = map[string]elbv2iface.ELBV2API{"app": svc}
+
+	return client
+}
+
+func TestClient_GetCurrentWeights(t *testing.T) {
+	tests := []struct {
+		name string
+		elb  *fakeELB
+		want *config.ServiceWeights
+	}{
+		{
+			name: "forward_rule",
+			elb:  &fakeELB{rules: []*elbv2.Rule{newForwardRule(90, 10)}},
+			want: &config.ServiceWeights{Primary: 90, Canary: 10},
+		},
+		{
+			name: "describe_failed",
+			elb:  &fakeELB{describeErr: errors.New("throttled")},
+			want: nil,
+		},
+		{
+			name: "rule_missing",
+			elb:  &fakeELB{},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestELBClient(tt.elb)
+
+			if got := client.GetCurrentWeights("app"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCurrentWeights() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_UpdateWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		elb     *fakeELB
+		wantErr bool
+	}{
+		{
+			name: "forward_rule",
+			elb:  &fakeELB{rules: []*elbv2.Rule{newForwardRule(100, 0)}},
+		},
+		{
+			name:    "describe_failed",
+			elb:     &fakeELB{describeErr: errors.New("throttled")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestELBClient(tt.elb)
+
+			err := client.UpdateWeights("app", &config.ServiceWeights{Primary: 75, Canary: 25})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateWeights() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if tt.elb.modified != nil {
+					t.Errorf("UpdateWeights() modified the rule after failing to read it")
+				}
+				return
+			}
+
+			groups := tt.elb.modified.Actions[0].ForwardConfig.TargetGroups
+			if aws.Int64Value(groups[0].Weight) != 75 || aws.Int64Value(groups[1].Weight) != 25 {
+				t.Errorf("UpdateWeights() sent %v", groups)
+			}
+		})
+	}
+}
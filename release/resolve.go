@@ -0,0 +1,340 @@
+package release
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// describeTagsBatchSize the most resources elbv2 DescribeTags accepts in one call
+const describeTagsBatchSize = 20
+
+// resolveMu serializes resolving, the processors of the api server share the config the arns are written to
+var resolveMu sync.Mutex
+
+// ResolveARNs fills in the cluster, target group and listener rule arns of the service when it configures them by
+// name or tags. Only the service a command works on is resolved, so a misconfigured service doesn't block the others
+func (c *Client) ResolveARNs(service string) error {
+	resolveMu.Lock()
+	defer resolveMu.Unlock()
+
+	if c.Config.Services[service] == nil {
+		return fmt.Errorf("unknown service: %s", service)
+	}
+
+	if err := c.resolveService(service); err != nil {
+		return fmt.Errorf("failed to resolve %s: %s", service, err)
+	}
+
+	return nil
+}
+
+func (c *Client) resolveService(service string) error {
+	settings := c.Config.Services[service]
+
+	if settings.ClusterARN == "" && settings.Cluster != "" {
+		arn, err := c.resolveCluster(service, settings.Cluster)
+		if err != nil {
+			return err
+		}
+		settings.ClusterARN = arn
+	}
+
+	for _, pool := range []string{"canary", "primary"} {
+		poolConfig := c.Config.GetPoolConfig(service, pool)
+		if poolConfig == nil || poolConfig.TargetGroupARN != "" {
+			continue
+		}
+
+		var arn string
+		var err error
+		if poolConfig.TargetGroupName != "" {
+			arn, err = c.resolveTargetGroupByName(service, poolConfig.TargetGroupName)
+		} else if len(poolConfig.TargetGroupTags) > 0 {
+			arn, err = c.resolveTargetGroupByTags(service, poolConfig.TargetGroupTags)
+		}
+		if err != nil {
+			return fmt.Errorf("%s target group: %s", pool, err)
+		}
+		poolConfig.TargetGroupARN = arn
+	}
+
+	if settings.ListenerARN == "" && settings.ListenerRule != nil {
+		arn, err := c.resolveListenerRule(service, settings.ListenerRule.LoadBalancer, settings.ListenerRule.Port,
+			settings.ListenerRule.Priority, settings.ListenerRule.Path)
+		if err != nil {
+			return fmt.Errorf("listener rule: %s", err)
+		}
+		settings.ListenerARN = arn
+	}
+
+	c.logFor(service, "").Debugf("[resolve.resolveService] cluster: %s listener rule: %s", settings.ClusterARN, settings.ListenerARN)
+
+	return nil
+}
+
+// cached returns the value stored under key for the services region, calling lookup and storing its result on a miss
+func (c *Client) cached(service, key string, lookup func() (string, error)) (string, error) {
+	key = c.Config.GetRegion(service) + "|" + key
+	if value, ok := c.arnCache[key]; ok {
+		return value, nil
+	}
+
+	value, err := lookup()
+	if err != nil {
+		return "", err
+	}
+
+	c.arnCache[key] = value
+	return value, nil
+}
+
+func (c *Client) resolveCluster(service, name string) (string, error) {
+	return c.cached(service, "cluster:"+name, func() (string, error) {
+		result, err := c.ecsSvc[service].DescribeClusters(&ecs.DescribeClustersInput{
+			Clusters: []*string{aws.String(name)},
+		})
+		if err != nil {
+			c.logAWSError(service, "", err)
+			return "", err
+		}
+
+		for _, cluster := range result.Clusters {
+			if aws.StringValue(cluster.ClusterName) == name && aws.StringValue(cluster.Status) == "ACTIVE" {
+				return aws.StringValue(cluster.ClusterArn), nil
+			}
+		}
+
+		return "", fmt.Errorf("no active cluster named %s", name)
+	})
+}
+
+func (c *Client) resolveTargetGroupByName(service, name string) (string, error) {
+	return c.cached(service, "target-group:"+name, func() (string, error) {
+		result, err := c.elbv2Svc[service].DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+			Names: []*string{aws.String(name)},
+		})
+		if err != nil {
+			c.logAWSError(service, "", err)
+			return "", err
+		}
+
+		if len(result.TargetGroups) != 1 {
+			return "", fmt.Errorf("found %d target groups named %s", len(result.TargetGroups), name)
+		}
+
+		return aws.StringValue(result.TargetGroups[0].TargetGroupArn), nil
+	})
+}
+
+func (c *Client) resolveTargetGroupByTags(service string, selector map[string]string) (string, error) {
+	return c.cached(service, "target-group-tags:"+formatTags(selector), func() (string, error) {
+		svc := c.elbv2Svc[service]
+
+		arns := []*string{}
+		err := svc.DescribeTargetGroupsPages(&elbv2.DescribeTargetGroupsInput{}, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+			for _, targetGroup := range page.TargetGroups {
+				arns = append(arns, targetGroup.TargetGroupArn)
+			}
+			return true
+		})
+		if err != nil {
+			c.logAWSError(service, "", err)
+			return "", err
+		}
+
+		descriptions := []*elbv2.TagDescription{}
+		for start := 0; start < len(arns); start += describeTagsBatchSize {
+			end := start + describeTagsBatchSize
+			if end > len(arns) {
+				end = len(arns)
+			}
+
+			result, err := svc.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: arns[start:end]})
+			if err != nil {
+				c.logAWSError(service, "", err)
+				return "", err
+			}
+			descriptions = append(descriptions, result.TagDescriptions...)
+		}
+
+		return selectTaggedResource(descriptions, selector)
+	})
+}
+
+func (c *Client) resolveListenerRule(service, loadBalancer string, port int64, priority, path string) (string, error) {
+	key := fmt.Sprintf("listener-rule:%s:%d:%s:%s", loadBalancer, port, priority, path)
+	return c.cached(service, key, func() (string, error) {
+		if priority == "" && path == "" {
+			return "", fmt.Errorf("a priority or path is required to select a rule of %s", loadBalancer)
+		}
+
+		listenerARN, err := c.resolveListener(service, loadBalancer, port)
+		if err != nil {
+			return "", err
+		}
+
+		rules := []*elbv2.Rule{}
+		input := &elbv2.DescribeRulesInput{ListenerArn: aws.String(listenerARN)}
+		for {
+			result, err := c.elbv2Svc[service].DescribeRules(input)
+			if err != nil {
+				c.logAWSError(service, "", err)
+				return "", err
+			}
+			rules = append(rules, result.Rules...)
+
+			if aws.StringValue(result.NextMarker) == "" {
+				break
+			}
+			input.Marker = result.NextMarker
+		}
+
+		return selectRule(rules, priority, path)
+	})
+}
+
+func (c *Client) resolveListener(service, loadBalancer string, port int64) (string, error) {
+	return c.cached(service, fmt.Sprintf("listener:%s:%d", loadBalancer, port), func() (string, error) {
+		svc := c.elbv2Svc[service]
+
+		balancers, err := svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+			Names: []*string{aws.String(loadBalancer)},
+		})
+		if err != nil {
+			c.logAWSError(service, "", err)
+			return "", err
+		}
+
+		if len(balancers.LoadBalancers) != 1 {
+			return "", fmt.Errorf("found %d load balancers named %s", len(balancers.LoadBalancers), loadBalancer)
+		}
+
+		listeners := []*elbv2.Listener{}
+		err = svc.DescribeListenersPages(&elbv2.DescribeListenersInput{
+			LoadBalancerArn: balancers.LoadBalancers[0].LoadBalancerArn,
+		}, func(page *elbv2.DescribeListenersOutput, lastPage bool) bool {
+			listeners = append(listeners, page.Listeners...)
+			return true
+		})
+		if err != nil {
+			c.logAWSError(service, "", err)
+			return "", err
+		}
+
+		return selectListener(listeners, loadBalancer, port)
+	})
+}
+
+// selectListener picks the listener on port, a port of 0 is only allowed when the load balancer has a single listener
+func selectListener(listeners []*elbv2.Listener, loadBalancer string, port int64) (string, error) {
+	matches := []string{}
+	for _, listener := range listeners {
+		if port == 0 || aws.Int64Value(listener.Port) == port {
+			matches = append(matches, aws.StringValue(listener.ListenerArn))
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%s has no listener on port %d", loadBalancer, port)
+	case 1:
+		return matches[0], nil
+	}
+
+	return "", fmt.Errorf("%s has %d listeners, set a port to pick one of: %s", loadBalancer, len(matches), strings.Join(matches, ", "))
+}
+
+// selectRule picks the single rule matching the priority and path, either of which may be empty
+func selectRule(rules []*elbv2.Rule, priority, path string) (string, error) {
+	matches := []string{}
+	for _, rule := range rules {
+		if priority != "" && aws.StringValue(rule.Priority) != priority {
+			continue
+		}
+
+		if path != "" && !hasPathCondition(rule, path) {
+			continue
+		}
+
+		matches = append(matches, aws.StringValue(rule.RuleArn))
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no rule matches priority %q path %q", priority, path)
+	case 1:
+		return matches[0], nil
+	}
+
+	return "", fmt.Errorf("%d rules match priority %q path %q: %s", len(matches), priority, path, strings.Join(matches, ", "))
+}
+
+func hasPathCondition(rule *elbv2.Rule, path string) bool {
+	for _, condition := range rule.Conditions {
+		if aws.StringValue(condition.Field) != "path-pattern" {
+			continue
+		}
+
+		values := condition.Values
+		if condition.PathPatternConfig != nil {
+			values = append(values, condition.PathPatternConfig.Values...)
+		}
+
+		for _, value := range values {
+			if aws.StringValue(value) == path {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// selectTaggedResource picks the single resource carrying every tag of the selector
+func selectTaggedResource(descriptions []*elbv2.TagDescription, selector map[string]string) (string, error) {
+	matches := []string{}
+	for _, description := range descriptions {
+		tags := map[string]string{}
+		for _, tag := range description.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+
+		matched := true
+		for key, value := range selector {
+			if actual, ok := tags[key]; !ok || actual != value {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			matches = append(matches, aws.StringValue(description.ResourceArn))
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no target group is tagged %s", formatTags(selector))
+	case 1:
+		return matches[0], nil
+	}
+
+	sort.Strings(matches)
+	return "", fmt.Errorf("%d target groups are tagged %s: %s", len(matches), formatTags(selector), strings.Join(matches, ", "))
+}
+
+func formatTags(tags map[string]string) string {
+	pairs := []string{}
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,62 @@
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/chriskuchin/pompeii/config"
+)
+
+// fakeClusters answers DescribeClusters with the named active cluster, or err when it is set
+type fakeClusters struct {
+	fakeECS
+	err   error
+	calls int
+}
+
+func (f *fakeClusters) DescribeClusters(input *ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	name := aws.StringValue(input.Clusters[0])
+	return &ecs.DescribeClustersOutput{Clusters: []*ecs.Cluster{{
+		ClusterName: aws.String(name),
+		ClusterArn:  aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/" + name),
+		Status:      aws.String("ACTIVE"),
+	}}}, nil
+}
+
+func TestClient_ResolveARNs(t *testing.T) {
+	app := &fakeClusters{}
+	broken := &fakeClusters{err: errors.New("AccessDenied")}
+	client := newTestClient(&fakeECS{})
+	client.Config.Services["app"].ClusterARN = ""
+	client.Config.Services["app"].Cluster = "production"
+	client.Config.Services["broken"] = &config.ServiceConfig{Cluster: "staging"}
+	client.ecsSvc = map[string]ecsiface.ECSAPI{"app": app, "broken": broken}
+
+	if err := client.ResolveARNs("app"); err != nil {
+		t.Fatalf("ResolveARNs(app) error = %v, a misconfigured service blocked another one", err)
+	}
+
+	if got := client.Config.Services["app"].ClusterARN; got != "arn:aws:ecs:us-east-1:123456789012:cluster/production" {
+		t.Errorf("ResolveARNs(app) cluster arn = %s", got)
+	}
+
+	if broken.calls != 0 {
+		t.Errorf("ResolveARNs(app) resolved another service %d times", broken.calls)
+	}
+
+	if err := client.ResolveARNs("broken"); err == nil {
+		t.Errorf("ResolveARNs(broken) error = nil, want the describe error")
+	}
+
+	if err := client.ResolveARNs("missing"); err == nil {
+		t.Errorf("ResolveARNs(missing) error = nil, want unknown service")
+	}
+}
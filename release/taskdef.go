@@ -0,0 +1,125 @@
+package release
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+type (
+	// TaskDefinitionRevision a registered revision of a task definition family
+	TaskDefinitionRevision struct {
+		ARN          string
+		Family       string
+		Revision     int64
+		RegisteredAt time.Time
+		Images       []string
+	}
+)
+
+// ListTaskDefinitionRevisions returns the active revisions of the pools task definition family, newest first
+func (c *Client) ListTaskDefinitionRevisions(service, pool string) ([]*TaskDefinitionRevision, error) {
+	state := c.GetCurrentServiceState(service, pool)
+	if state == nil {
+		return nil, fmt.Errorf("failed to describe the %s pool of %s", pool, service)
+	}
+
+	current, err := c.DescribeTaskDefinition(service, state.TaskDef)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := c.ecsSvc[service]
+	arns := []*string{}
+	err = svc.ListTaskDefinitionsPages(&ecs.ListTaskDefinitionsInput{
+		FamilyPrefix: aws.String(current.Family),
+		Status:       aws.String(ecs.TaskDefinitionStatusActive),
+		Sort:         aws.String(ecs.SortOrderDesc),
+	}, func(page *ecs.ListTaskDefinitionsOutput, lastPage bool) bool {
+		arns = append(arns, page.TaskDefinitionArns...)
+		return true
+	})
+	if err != nil {
+		c.logAWSError(service, pool, err)
+		return nil, err
+	}
+
+	revisions := []*TaskDefinitionRevision{}
+	for _, arn := range arns {
+		revision, err := c.DescribeTaskDefinition(service, aws.StringValue(arn))
+		if err != nil {
+			return nil, err
+		}
+
+		// the family prefix also matches longer family names
+		if revision.Family == current.Family {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	return revisions, nil
+}
+
+// DescribeTaskDefinition returns the revision details of a task definition
+func (c *Client) DescribeTaskDefinition(service, taskDef string) (*TaskDefinitionRevision, error) {
+	svc := c.ecsSvc[service]
+	result, err := svc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDef),
+	})
+	if err != nil {
+		c.logAWSError(service, "", err)
+		return nil, err
+	}
+
+	definition := result.TaskDefinition
+	revision := &TaskDefinitionRevision{
+		ARN:          aws.StringValue(definition.TaskDefinitionArn),
+		Family:       aws.StringValue(definition.Family),
+		Revision:     aws.Int64Value(definition.Revision),
+		RegisteredAt: aws.TimeValue(definition.RegisteredAt),
+	}
+
+	for _, container := range definition.ContainerDefinitions {
+		revision.Images = append(revision.Images, aws.StringValue(container.Image))
+	}
+
+	return revision, nil
+}
+
+// SelectRevision picks the revision named by to from revisions sorted newest first.
+// to is a revision number, family:revision, a task definition arn or previous-N counting back from current
+func SelectRevision(revisions []*TaskDefinitionRevision, current, to string) (*TaskDefinitionRevision, error) {
+	if strings.HasPrefix(to, "previous") {
+		back := 1
+		if suffix := strings.TrimPrefix(to, "previous"); suffix != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid revision: %s", to)
+			}
+			back = n
+		}
+
+		for i, revision := range revisions {
+			if revision.ARN == current {
+				if i+back >= len(revisions) {
+					return nil, fmt.Errorf("only %d earlier revisions are active", len(revisions)-i-1)
+				}
+				return revisions[i+back], nil
+			}
+		}
+
+		return nil, fmt.Errorf("current task definition %s is not an active revision", current)
+	}
+
+	for _, revision := range revisions {
+		if revision.ARN == to || fmt.Sprintf("%s:%d", revision.Family, revision.Revision) == to || strconv.FormatInt(revision.Revision, 10) == to {
+			return revision, nil
+		}
+	}
+
+	return nil, fmt.Errorf("revision not found: %s", to)
+}
@@ -0,0 +1,46 @@
+package release
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSelectRevision(t *testing.T) {
+	revisions := []*TaskDefinitionRevision{}
+	for revision := int64(5); revision > 0; revision-- {
+		revisions = append(revisions, &TaskDefinitionRevision{
+			ARN:      fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:task-definition/app:%d", revision),
+			Family:   "app",
+			Revision: revision,
+		})
+	}
+	current := revisions[1].ARN
+
+	tests := []struct {
+		name    string
+		to      string
+		want    int64
+		wantErr bool
+	}{
+		{name: "previous", to: "previous", want: 3},
+		{name: "previous_2", to: "previous-2", want: 2},
+		{name: "previous_too_far", to: "previous-4", wantErr: true},
+		{name: "previous_invalid", to: "previous-x", wantErr: true},
+		{name: "revision", to: "1", want: 1},
+		{name: "family_revision", to: "app:5", want: 5},
+		{name: "arn", to: revisions[2].ARN, want: 3},
+		{name: "missing", to: "9", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectRevision(revisions, current, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectRevision() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && got.Revision != tt.want {
+				t.Errorf("SelectRevision() = %d, want %d", got.Revision, tt.want)
+			}
+		})
+	}
+}
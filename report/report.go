@@ -0,0 +1,173 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chriskuchin/pompeii/workflow"
+)
+
+type (
+	testSuites struct {
+		XMLName xml.Name    `xml:"testsuites"`
+		Suites  []testSuite `xml:"testsuite"`
+	}
+
+	testSuite struct {
+		Name      string     `xml:"name,attr"`
+		Tests     int        `xml:"tests,attr"`
+		Failures  int        `xml:"failures,attr"`
+		Skipped   int        `xml:"skipped,attr"`
+		Time      float64    `xml:"time,attr"`
+		Timestamp string     `xml:"timestamp,attr,omitempty"`
+		Cases     []testCase `xml:"testcase"`
+	}
+
+	testCase struct {
+		Name      string   `xml:"name,attr"`
+		ClassName string   `xml:"classname,attr"`
+		Time      float64  `xml:"time,attr"`
+		Failure   *failure `xml:"failure,omitempty"`
+		Skipped   *skipped `xml:"skipped,omitempty"`
+		SystemOut string   `xml:"system-out,omitempty"`
+	}
+
+	failure struct {
+		Message string `xml:"message,attr"`
+		Body    string `xml:",chardata"`
+	}
+
+	skipped struct {
+		Message string `xml:"message,attr"`
+	}
+)
+
+const (
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// FormatFor returns the requested format, falling back to the path extension when none is given
+func FormatFor(path, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".xml" {
+		return FormatJUnit
+	}
+
+	return FormatJSON
+}
+
+// WriteFile writes the run report to path in the given format
+func WriteFile(path, format string, run *workflow.Run) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return Write(file, FormatFor(path, format), run)
+}
+
+// Write writes the run report in the given format
+func Write(w io.Writer, format string, run *workflow.Run) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(run)
+	case FormatJUnit:
+		return writeJUnit(w, run)
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+}
+
+func writeJUnit(w io.Writer, run *workflow.Run) error {
+	suite := testSuite{
+		Name: fmt.Sprintf("%s/%s", run.Service, run.Workflow),
+		Time: seconds(run.StartedAt, run.FinishedAt),
+	}
+
+	if !run.StartedAt.IsZero() {
+		suite.Timestamp = run.StartedAt.Format("2006-01-02T15:04:05")
+	}
+
+	for _, step := range run.Steps {
+		tc := testCase{
+			Name:      fmt.Sprintf("step %d: %s %s", step.Index, step.Action.Type, step.Action.Target),
+			ClassName: suite.Name,
+			Time:      seconds(step.StartedAt, step.FinishedAt),
+			SystemOut: describe(step),
+		}
+
+		switch step.Status {
+		case workflow.RunFailed:
+			suite.Failures++
+			tc.Failure = &failure{Message: step.Error, Body: step.Result}
+		case workflow.RunSkipped:
+			suite.Skipped++
+			tc.Skipped = &skipped{Message: step.Error}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if run.Rollback != nil {
+		suite.Cases = append(suite.Cases, testCase{
+			Name:      "rollback",
+			ClassName: suite.Name,
+			Time:      seconds(run.Rollback.StartedAt, run.Rollback.FinishedAt),
+			SystemOut: marshal(run.Rollback),
+		})
+	}
+
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(&testSuites{Suites: []testSuite{suite}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func describe(step *workflow.StepRecord) string {
+	return marshal(map[string]interface{}{
+		"action": step.Action,
+		"result": step.Result,
+		"before": step.Before,
+		"after":  step.After,
+	})
+}
+
+func marshal(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(raw)
+}
+
+func seconds(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+
+	return end.Sub(start).Seconds()
+}
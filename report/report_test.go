@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/workflow"
+)
+
+func testRun() *workflow.Run {
+	started := time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+	return &workflow.Run{
+		ID:         "run1",
+		Service:    "service1",
+		Workflow:   "default",
+		Status:     workflow.RunRolledBack,
+		StartedAt:  started,
+		FinishedAt: started.Add(time.Minute),
+		Steps: []*workflow.StepRecord{
+			{
+				Index:      1,
+				Action:     &config.Action{Type: config.TrafficShift, Target: "canary", Ratio: 10},
+				Status:     workflow.RunSucceeded,
+				StartedAt:  started,
+				FinishedAt: started.Add(10 * time.Second),
+				Before: &workflow.Checkpoint{
+					Weights: &config.ServiceWeights{Primary: 100},
+				},
+				After: &workflow.Checkpoint{
+					Weights: &config.ServiceWeights{Canary: 10, Primary: 90},
+				},
+			},
+			{
+				Index:      2,
+				Action:     &config.Action{Type: config.ValidatePool, Target: "task"},
+				Status:     workflow.RunFailed,
+				Error:      "Failed to validate pools",
+				Result:     "task: failed",
+				StartedAt:  started.Add(10 * time.Second),
+				FinishedAt: started.Add(40 * time.Second),
+			},
+		},
+		Rollback: &workflow.RollbackRecord{
+			Reason:     "Failed to validate pools",
+			StartedAt:  started.Add(40 * time.Second),
+			FinishedAt: started.Add(time.Minute),
+		},
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := Write(out, FormatJSON, testRun()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &workflow.Run{}
+	if err := json.Unmarshal(out.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Steps) != 2 || got.Steps[0].After.Weights.Canary != 10 || got.Rollback == nil {
+		t.Errorf("Write() = %s", out)
+	}
+}
+
+func TestWrite_JUnit(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := Write(out, FormatJUnit, testRun()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &testSuites{}
+	if err := xml.Unmarshal(out.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := got.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Cases[1].Failure == nil || suite.Cases[2].Name != "rollback" {
+		t.Errorf("Write() = %s", out)
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := []struct {
+		path   string
+		format string
+		want   string
+	}{
+		{path: "report.json", want: FormatJSON},
+		{path: "report.xml", want: FormatJUnit},
+		{path: "report.out", format: "JUnit", want: FormatJUnit},
+	}
+	for _, tt := range tests {
+		if got := FormatFor(tt.path, tt.format); got != tt.want {
+			t.Errorf("FormatFor(%s, %s) = %s, want %s", tt.path, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+	"github.com/chriskuchin/pompeii/workflow"
+)
+
+type (
+	// Server exposes the workflow processor over a REST api
+	Server struct {
+		Config *config.Config
+		Token  string
+
+		// newProcessor creates the processor for a run, replaced in tests
+		newProcessor func(*config.Workflow) (Processor, error)
+
+		mu   sync.Mutex
+		runs map[string]Processor
+		// active the id of the run currently executing or rolling back for each service, holds counts the run and
+		// rollback requests keeping it active
+		active map[string]string
+		holds  map[string]int
+		// rollbacks the runs a rollback request is in flight for
+		rollbacks map[string]bool
+	}
+
+	// Processor the workflow operations the api drives
+	Processor interface {
+		Run() error
+		Status() *workflow.Run
+		Cancel()
+		Rollback() error
+		Fail(err error)
+	}
+
+	// StartRequest the body of POST /runs
+	StartRequest struct {
+		Service  string `json:"service"`
+		Workflow string `json:"workflow"`
+		TaskDef  string `json:"taskDef"`
+		Count    int64  `json:"count"`
+		// CanaryCount and PrimaryCount override count for one pool, a pool keeps its current count when neither is set
+		CanaryCount  int64             `json:"canaryCount"`
+		PrimaryCount int64             `json:"primaryCount"`
+		Parameters   map[string]string `json:"parameters"`
+		// OverrideFreeze runs the workflow while the service is frozen or outside of its deploy windows, Reason says why
+		OverrideFreeze bool   `json:"overrideFreeze"`
+		Reason         string `json:"reason"`
+	}
+
+	errorResponse struct {
+		Error string `json:"error"`
+	}
+)
+
+// New returns a server running workflows defined in cfg, requests must present token as a bearer token
+func New(cfg *config.Config, token string) *Server {
+	return &Server{
+		Config: cfg,
+		Token:  token,
+		newProcessor: func(wf *config.Workflow) (Processor, error) {
+			return workflow.NewProcessor(wf)
+		},
+		runs:      map[string]Processor{},
+		active:    map[string]string{},
+		holds:     map[string]int{},
+		rollbacks: map[string]bool{},
+	}
+}
+
+// ListenAndServe serves the api on addr
+func (s *Server) ListenAndServe(addr string) error {
+	logging.Infof("[server.ListenAndServe] Listening on %s", addr)
+	return http.ListenAndServe(addr, s)
+}
+
+// ServeHTTP routes the api requests
+//
+//	POST /runs                 start a workflow
+//	GET  /runs                 list runs
+//	GET  /runs/<id>            run status and step history
+//	POST /runs/<id>/cancel     stop the run before its next step
+//	POST /runs/<id>/rollback   roll the run back to its checkpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, &errorResponse{Error: "unauthorized"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "runs" {
+		writeJSON(w, http.StatusNotFound, &errorResponse{Error: "not found"})
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.startRun(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.listRuns(w)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.withRun(w, parts[1], func(p Processor) {
+			writeJSON(w, http.StatusOK, p.Status())
+		})
+	case len(parts) == 3 && parts[2] == "cancel" && r.Method == http.MethodPost:
+		s.withRun(w, parts[1], func(p Processor) {
+			p.Cancel()
+			writeJSON(w, http.StatusAccepted, p.Status())
+		})
+	case len(parts) == 3 && parts[2] == "rollback" && r.Method == http.MethodPost:
+		s.withRun(w, parts[1], func(p Processor) {
+			s.rollbackRun(w, parts[1], p)
+		})
+	default:
+		writeJSON(w, http.StatusNotFound, &errorResponse{Error: "not found"})
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	request := &StartRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: err.Error()})
+		return
+	}
+
+	if _, ok := s.Config.Services[request.Service]; !ok {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: fmt.Sprintf("unknown service: %s", request.Service)})
+		return
+	}
+
+	if request.Workflow == "" {
+		request.Workflow = s.Config.GetWorkflow(request.Service)
+	}
+
+	definition := s.Config.GetWorkflowDefinition(request.Service, request.Workflow)
+	if definition == nil {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: fmt.Sprintf("unknown workflow: %s", request.Workflow)})
+		return
+	}
+
+	steps, parameters, err := definition.Bind(request.Parameters)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: err.Error()})
+		return
+	}
+
+	if request.TaskDef == "" {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: "taskDef is required"})
+		return
+	}
+
+	override := ""
+	if request.OverrideFreeze {
+		if strings.TrimSpace(request.Reason) == "" {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{Error: "reason is required to override a freeze"})
+			return
+		}
+		override = request.Reason
+	}
+
+	processor, err := s.newProcessor(&config.Workflow{
+		Config:         s.Config,
+		Service:        request.Service,
+		Name:           request.Workflow,
+		Parameters:     parameters,
+		RollbackPolicy: definition.RollbackPolicy,
+		OverrideReason: override,
+		Steps:          steps,
+		Default: &config.ServiceState{
+			TaskDef: request.TaskDef,
+			Count:   request.Count,
+		},
+		PoolDefaults: map[string]*config.ServiceState{
+			"canary":  {Count: request.CanaryCount},
+			"primary": {Count: request.PrimaryCount},
+		},
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: err.Error()})
+		return
+	}
+
+	run := processor.Status()
+
+	s.mu.Lock()
+	if id, ok := s.active[request.Service]; ok {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, &errorResponse{Error: fmt.Sprintf("run %s is already in progress for %s", id, request.Service)})
+		return
+	}
+	s.hold(request.Service, run.ID)
+	s.runs[run.ID] = processor
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.release(request.Service)
+			s.mu.Unlock()
+		}()
+		defer s.recoverRun(run.ID, processor)
+
+		if err := processor.Run(); err != nil {
+			logging.Errorf("[server.startRun] run: %s err: %s", run.ID, err)
+		}
+	}()
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// rollbackRun asks the run to roll back. The run keeps its service active until the rollback is done, so no other run
+// or rollback of the service starts meanwhile
+func (s *Server) rollbackRun(w http.ResponseWriter, id string, processor Processor) {
+	service := processor.Status().Service
+
+	s.mu.Lock()
+	if s.rollbacks[id] {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, &errorResponse{Error: fmt.Sprintf("run %s is already rolling back", id)})
+		return
+	}
+	if active, ok := s.active[service]; ok && active != id {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, &errorResponse{Error: fmt.Sprintf("run %s is already in progress for %s", active, service)})
+		return
+	}
+	s.hold(service, id)
+	s.rollbacks[id] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.rollbacks, id)
+			s.release(service)
+			s.mu.Unlock()
+		}()
+		defer s.recoverRun(id, processor)
+
+		if err := processor.Rollback(); err != nil {
+			logging.Errorf("[server.rollback] run: %s err: %s", id, err)
+		}
+	}()
+
+	writeJSON(w, http.StatusAccepted, processor.Status())
+}
+
+// recoverRun keeps a panicking run from taking the server and the other runs down with it, the run is marked failed
+func (s *Server) recoverRun(id string, processor Processor) {
+	if r := recover(); r != nil {
+		err := fmt.Errorf("panic: %v", r)
+		logging.Errorf("[server.recoverRun] run: %s err: %s\n%s", id, err, debug.Stack())
+		processor.Fail(err)
+	}
+}
+
+// hold makes id the active run of service until it is released. The caller holds mu
+func (s *Server) hold(service, id string) {
+	s.active[service] = id
+	s.holds[service]++
+}
+
+// release drops a hold on the active run of service, the service is free once nothing holds it. The caller holds mu
+func (s *Server) release(service string) {
+	s.holds[service]--
+	if s.holds[service] <= 0 {
+		delete(s.holds, service)
+		delete(s.active, service)
+	}
+}
+
+func (s *Server) listRuns(w http.ResponseWriter) {
+	s.mu.Lock()
+	runs := []*workflow.Run{}
+	for _, processor := range s.runs {
+		runs = append(runs, processor.Status())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) withRun(w http.ResponseWriter, id string, handle func(Processor)) {
+	s.mu.Lock()
+	processor, ok := s.runs[id]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, &errorResponse{Error: fmt.Sprintf("unknown run: %s", id)})
+		return
+	}
+
+	handle(processor)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logging.Errorf("[server.writeJSON] %s", err)
+	}
+}
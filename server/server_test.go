@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/workflow"
+)
+
+type fakeProcessor struct {
+	run       *workflow.Run
+	release   chan struct{}
+	cancelled bool
+	// rolledBack when set blocks Rollback until it is closed
+	rolledBack chan struct{}
+	// panics makes Run and Rollback panic once released
+	panics bool
+	failed chan error
+}
+
+func (f *fakeProcessor) Run() error {
+	<-f.release
+	if f.panics {
+		panic("nil pointer dereference")
+	}
+	return nil
+}
+
+func (f *fakeProcessor) Status() *workflow.Run { return f.run }
+func (f *fakeProcessor) Cancel()               { f.cancelled = true }
+
+func (f *fakeProcessor) Fail(err error) { f.failed <- err }
+
+func (f *fakeProcessor) Rollback() error {
+	if f.rolledBack != nil {
+		<-f.rolledBack
+	}
+	if f.panics {
+		panic("nil pointer dereference")
+	}
+	return nil
+}
+
+func newTestServer() (*Server, *fakeProcessor) {
+	fake := &fakeProcessor{
+		run:     &workflow.Run{ID: "run1", Service: "service1", Status: workflow.RunRunning},
+		release: make(chan struct{}),
+	}
+
+	s := New(&config.Config{
+		Services: map[string]*config.ServiceConfig{
+			"service1": {},
+		},
+		Workflows: config.WorkflowConfig{
+			"default": {},
+		},
+	}, "secret")
+	s.newProcessor = func(*config.Workflow) (Processor, error) {
+		return fake, nil
+	}
+
+	return s, fake
+}
+
+func do(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	s, _ := newTestServer()
+
+	if w := do(s, http.MethodGet, "/runs", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /runs without token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if w := do(s, http.MethodGet, "/runs", "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /runs with wrong token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestServer_RunLifecycle(t *testing.T) {
+	s, fake := newTestServer()
+	defer close(fake.release)
+
+	w := do(s, http.MethodPost, "/runs", "secret", `{"service":"service1","taskDef":"task:2"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /runs = %d %s, want %d", w.Code, w.Body, http.StatusAccepted)
+	}
+
+	if w := do(s, http.MethodPost, "/runs", "secret", `{"service":"service1","taskDef":"task:3"}`); w.Code != http.StatusConflict {
+		t.Errorf("second POST /runs = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = do(s, http.MethodGet, "/runs/run1", "secret", "")
+	got := &workflow.Run{}
+	if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "run1" || got.Status != workflow.RunRunning {
+		t.Errorf("GET /runs/run1 = %+v", got)
+	}
+
+	if w := do(s, http.MethodPost, "/runs/run1/cancel", "secret", ""); w.Code != http.StatusAccepted || !fake.cancelled {
+		t.Errorf("POST /runs/run1/cancel = %d cancelled: %v", w.Code, fake.cancelled)
+	}
+
+	if w := do(s, http.MethodGet, "/runs/missing", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /runs/missing = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestServer_StartValidation(t *testing.T) {
+	s, _ := newTestServer()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "unknown_service", body: `{"service":"other","taskDef":"task:2"}`},
+		{name: "unknown_workflow", body: `{"service":"service1","workflow":"other","taskDef":"task:2"}`},
+		{name: "missing_task_def", body: `{"service":"service1"}`},
+		{name: "invalid_json", body: `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(s, http.MethodPost, "/runs", "secret", tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("POST /runs = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestServer_RollbackFinishedRun(t *testing.T) {
+	s, _ := newTestServer()
+	finished := &fakeProcessor{
+		run:        &workflow.Run{ID: "run0", Service: "service1", Status: workflow.RunSucceeded},
+		rolledBack: make(chan struct{}),
+	}
+	defer close(finished.rolledBack)
+	s.runs["run0"] = finished
+
+	if w := do(s, http.MethodPost, "/runs/run0/rollback", "secret", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("POST /runs/run0/rollback = %d %s, want %d", w.Code, w.Body, http.StatusAccepted)
+	}
+
+	if w := do(s, http.MethodPost, "/runs/run0/rollback", "secret", ""); w.Code != http.StatusConflict {
+		t.Errorf("second POST /runs/run0/rollback = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	if w := do(s, http.MethodPost, "/runs", "secret", `{"service":"service1","taskDef":"task:2"}`); w.Code != http.StatusConflict {
+		t.Errorf("POST /runs during a rollback = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestServer_PanickingRun(t *testing.T) {
+	s, fake := newTestServer()
+	fake.panics = true
+	fake.failed = make(chan error, 3)
+	close(fake.release)
+
+	// a new run of the service is only accepted once the panicking one released it
+	start := func() {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if w := do(s, http.MethodPost, "/runs", "secret", `{"service":"service1","taskDef":"task:2"}`); w.Code == http.StatusAccepted {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("POST /runs never accepted")
+	}
+
+	start()
+	if err := <-fake.failed; err == nil || !strings.Contains(err.Error(), "nil pointer dereference") {
+		t.Errorf("Fail() err = %v", err)
+	}
+	start()
+	<-fake.failed
+
+	if w := do(s, http.MethodPost, "/runs/run1/rollback", "secret", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("POST /runs/run1/rollback = %d %s, want %d", w.Code, w.Body, http.StatusAccepted)
+	}
+	if err := <-fake.failed; err == nil {
+		t.Errorf("Fail() not called for a panicking rollback")
+	}
+	start()
+}
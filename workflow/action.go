@@ -5,105 +5,726 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/chriskuchin/pompeii/approval"
+	"github.com/chriskuchin/pompeii/audit"
 	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+	"github.com/chriskuchin/pompeii/metrics"
+	"github.com/chriskuchin/pompeii/notify"
 	"github.com/chriskuchin/pompeii/release"
-	"github.com/prometheus/common/log"
 )
 
 type (
+	// releaseClient the calls a processor makes against aws, replaced by a fake in tests
+	releaseClient interface {
+		GetCurrentWeights(service string) *config.ServiceWeights
+		UpdateWeights(service string, weights *config.ServiceWeights) error
+		GetTargetHealth(service, pool string) (*config.TargetHealth, error)
+		GetCurrentServiceState(service, pool string) *config.ServiceState
+		Deploy(service, pool string, state *config.ServiceState) bool
+		Scale(service, pool string, count int64) bool
+		GetScalingState(service, pool string) (*config.ScalingState, error)
+		UpdateScalingState(service, pool string, state *config.ScalingState) error
+		StartAndMonitorTask(service, task, container string, command []string) bool
+		SetLogger(logger *logging.Entry)
+	}
+
 	Processor struct {
 		workflow *config.Workflow
-		client   *release.Client
+		client   releaseClient
+		notifier *notify.Notifier
+		record   *runRecord
+		audit    audit.Store
+
+		// metrics the last-run gauges of the run, pushed when it finishes
+		metrics *metrics.Recorder
 
+		// runLog carries the run fields, log adds the fields of the current step
+		runLog *logging.Entry
+		log    *logging.Entry
+
+		// checkpoint the state before the first step, latest the most recent checkpoint step or the start
 		checkpoint *Checkpoint
+		latest     *Checkpoint
+		// scaling the original auto scaling settings of the pools registered as scalable targets
+		scaling map[string]*config.ScalingState
+
+		// state where the run is saved when it pauses, next the index of the first step to run and resumed whether
+		// the run continues a paused run
+		state   StateStore
+		next    int
+		resumed bool
+
+		mu sync.Mutex
+		// result describes the outcome of the current validation step for the run record
+		result string
+
+		// stop set once a cancel or rollback is requested, the run stops before the next step. rollback stays set until
+		// a rollback is performed, one still pending when the run finishes is performed before Run returns
+		stop     bool
+		rollback bool
+		finished bool
+		// rollingBack set while a finished run is being rolled back
+		rollingBack bool
 	}
 
 	Checkpoint struct {
-		Canary  *config.ServiceState
-		Primary *config.ServiceState
-		Weights *config.ServiceWeights
+		Canary  *config.ServiceState   `json:"canary"`
+		Primary *config.ServiceState   `json:"primary"`
+		Weights *config.ServiceWeights `json:"weights"`
 	}
 )
 
+// ProcessWorkflow runs the workflow to completion
 func ProcessWorkflow(workflow *config.Workflow) error {
-	processor := &Processor{
+	processor, err := NewProcessor(workflow)
+	if err != nil {
+		return err
+	}
+
+	return processor.Run()
+}
+
+// NewProcessor prepares a workflow run without starting it
+func NewProcessor(workflow *config.Workflow) (*Processor, error) {
+	return newProcessor(workflow, nil)
+}
+
+// newProcessor prepares a processor recording to run, or to a new run when it is nil
+func newProcessor(workflow *config.Workflow, run *Run) (*Processor, error) {
+	notifier, err := notify.New(workflow.Config, workflow.Service)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to configure notifications: %s", err)
+	}
+
+	switch workflow.RollbackPolicy {
+	case "":
+		workflow.RollbackPolicy = config.RollbackToLastCheckpoint
+	case config.RollbackToLastCheckpoint, config.RollbackToStart:
+	default:
+		return nil, fmt.Errorf("Unknown rollback policy: %s", workflow.RollbackPolicy)
+	}
+
+	record := &runRecord{run: run}
+	if run == nil {
+		record = newRunRecord(workflow)
+	}
+
+	runLog := logging.WithFields(logging.Fields{
+		logging.RunID:    record.run.ID,
+		logging.Service:  workflow.Service,
+		logging.Workflow: workflow.Name,
+	})
+
+	recorder := metrics.NewRecorder()
+	client := release.NewClient(workflow.Config)
+	client.Logger = runLog
+	client.Metrics = recorder
+	if err := client.ResolveARNs(workflow.Service); err != nil {
+		return nil, err
+	}
+
+	return &Processor{
 		workflow:   workflow,
-		client:     release.NewClient(workflow.Config),
+		client:     client,
+		notifier:   notifier,
+		record:     record,
+		audit:      audit.NewStore(workflow.Config, workflow.Service),
+		metrics:    recorder,
+		runLog:     runLog,
+		log:        runLog,
 		checkpoint: &Checkpoint{},
+		latest:     &Checkpoint{},
+		scaling:    map[string]*config.ScalingState{},
+		state:      NewStateStore(workflow.Config),
+	}, nil
+}
+
+// enterStep annotates the processor and client logs with the current step
+func (p *Processor) enterStep(step int, action *config.Action) {
+	if action == nil {
+		p.log = p.runLog
+	} else {
+		p.log = p.runLog.WithFields(logging.Fields{
+			logging.Step:   step,
+			logging.Action: action.Type,
+		})
+	}
+
+	p.client.SetLogger(p.log)
+}
+
+// Status returns a snapshot of the run and its step history
+func (p *Processor) Status() *Run {
+	return p.record.snapshot()
+}
+
+// Cancel stops the run before its next step leaving the pools as they are
+func (p *Processor) Cancel() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stop = true
+}
+
+// Fail marks a run that ended abnormally as failed, the pools are left as they are and can still be rolled back
+func (p *Processor) Fail(err error) {
+	p.mu.Lock()
+	p.stop = true
+	p.rollback = false
+	p.finished = true
+	p.rollingBack = false
+	p.mu.Unlock()
+
+	p.record.finish(RunFailed, err)
+}
+
+// Rollback stops a running workflow before its next step and rolls back to the checkpoint,
+// a finished run is rolled back immediately unless it is already rolling back
+func (p *Processor) Rollback() error {
+	p.mu.Lock()
+	if !p.finished {
+		p.stop = true
+		p.rollback = true
+		p.mu.Unlock()
+		return nil
+	}
+
+	if p.rollingBack {
+		p.mu.Unlock()
+		return fmt.Errorf("a rollback is already in progress")
+	}
+	p.rollingBack = true
+	p.mu.Unlock()
+
+	err := p.rollbackFinished()
+	p.pushMetrics(p.record.snapshot(), p.captureState())
+
+	return err
+}
+
+// rollbackFinished rolls a finished run back, the caller sets rollingBack
+func (p *Processor) rollbackFinished() error {
+	defer func() {
+		p.mu.Lock()
+		p.rollingBack = false
+		p.mu.Unlock()
+	}()
+
+	if err := p.performRollback(0, nil, "Rollback requested"); err != nil {
+		p.record.finish(RunRollbackFailed, err)
+		return err
+	}
+	p.record.finish(RunRolledBack, nil)
+
+	return nil
+}
+
+// markFinished marks the run finished, returning whether a rollback requested while it ran is still pending. A
+// pending rollback is claimed by the caller so a concurrent Rollback is refused rather than run twice
+func (p *Processor) markFinished() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.finished = true
+	p.rollingBack = p.rollback
+	return p.rollback
+}
+
+func (p *Processor) stopRequested() (bool, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stop, p.rollback
+}
+
+// Run executes the workflow steps in order, rolling back to the checkpoint when a step fails
+func (p *Processor) Run() error {
+	err := p.run()
+
+	// a rollback requested during the last step or after it is performed here rather than lost
+	if p.markFinished() {
+		p.enterStep(0, nil)
+		if run := p.record.snapshot(); run.Status == RunPaused {
+			p.deletePausedRun(run.ID)
+		}
+
+		if rollbackErr := p.rollbackFinished(); rollbackErr != nil {
+			err = rollbackErr
+		}
+	}
+
+	run := p.record.snapshot()
+	p.notifyFinished(run)
+	final := p.captureState()
+	p.pushMetrics(run, final)
+	p.appendAuditRecord(run, final)
+
+	return err
+}
+
+// pushMetrics records the outcome of the run and pushes its metrics, so every way a run ends updates the dashboards
+func (p *Processor) pushMetrics(run *Run, final *Checkpoint) {
+	p.metrics.WorkflowFinished(string(run.Status), run.StartedAt, run.FinishedAt)
+	if final.Weights != nil {
+		p.metrics.CanaryWeight(final.Weights.Canary)
+	}
+
+	if err := p.metrics.Push(p.workflow.Config.Metrics, p.workflow.Service, p.workflow.Name); err != nil {
+		p.log.Errorf("[workflow.pushMetrics] Failed to push metrics: %s", err)
+	}
+}
+
+// appendAuditRecord records who ran the workflow and what it changed
+func (p *Processor) appendAuditRecord(run *Run, final *Checkpoint) {
+	if p.audit == nil {
+		return
+	}
+
+	record := &audit.Record{
+		RunID:     run.ID,
+		Service:   run.Service,
+		Workflow:  run.Workflow,
+		Actor:     audit.Actor(),
+		Identity:  audit.Identity(p.workflow.Config, p.workflow.Service),
+		Outcome:   string(run.Status),
+		Error:     run.Error,
+		StartedAt: run.StartedAt,
+		Duration:  run.FinishedAt.Sub(run.StartedAt),
+
+		OverrideReason: run.OverrideReason,
+		Overridden:     run.Overridden,
+	}
+
+	if p.checkpoint.Canary != nil {
+		record.CanaryBefore = p.checkpoint.Canary.TaskDef
+	}
+	if p.checkpoint.Primary != nil {
+		record.PrimaryBefore = p.checkpoint.Primary.TaskDef
+	}
+	if final.Canary != nil {
+		record.CanaryAfter = final.Canary.TaskDef
 	}
+	if final.Primary != nil {
+		record.PrimaryAfter = final.Primary.TaskDef
+	}
+
+	if err := p.audit.Append(record); err != nil {
+		p.runLog.Errorf("[workflow.appendAuditRecord] Failed to append audit record: %s", err)
+	}
+}
+
+func (p *Processor) finishStep(record *StepRecord, status RunStatus, reason string) {
+	result := p.result
+	p.result = ""
 
-	processor.getInitialCheckpoint()
+	p.record.finishStep(record, status, reason, result, p.captureState())
+	p.metrics.StepFinished(record.Index, string(record.Action.Type), string(status), record.FinishedAt.Sub(record.StartedAt))
+}
 
-	for _, action := range workflow.Steps {
-		switch action.Type {
-		case config.UpdatePool:
-			log.Infof("Update %s pool", action.Target)
-			proceed := processor.handleUpdateAction(action)
+func (p *Processor) run() error {
+	if p.resumed {
+		p.record.resume()
+		p.notify(notify.WorkflowResumed, p.next, nil, "")
+	} else {
+		p.record.start()
+		p.getInitialCheckpoint()
 
-			if !proceed {
-				log.Error("Pool Update Failed!!")
-				processor.rollbackToLatestCheckpoint()
-				return fmt.Errorf("Failed to update pool: rolled back")
+		// a run blocked by a closed deploy window or a freeze ends before it announces itself or changes anything
+		if len(p.workflow.Steps) > 0 {
+			if reason := p.checkDeployWindow(); reason != "" {
+				p.enterStep(1, p.workflow.Steps[0])
+				return p.block(1, p.workflow.Steps[0], reason)
 			}
+		}
+
+		p.notify(notify.WorkflowStarted, 0, nil, "")
+	}
 
-		case config.TrafficShift:
-			log.Infof("Shift traffic to pool: %s weight: %d", action.Target, action.Ratio)
-			proceed := processor.handleShiftAction(action)
+	defer p.restoreAutoScaling()
+	if err := p.prepareAutoScaling(); err != nil {
+		err = fmt.Errorf("Failed to prepare auto scaling: %s", err)
+		p.record.finish(RunFailed, err)
+		return err
+	}
+
+	for i := p.next; i < len(p.workflow.Steps); i++ {
+		action, step := p.workflow.Steps[i], i+1
 
-			if !proceed {
-				log.Error("Traffic Shift Failed!!")
-				processor.rollbackToLatestCheckpoint()
-				return fmt.Errorf("Failed to shift traffic: rolled back")
+		if stop, rollback := p.stopRequested(); stop {
+			if rollback {
+				return p.fail(step, action, nil, "Rollback requested")
 			}
 
-		case config.ValidatePool:
-			log.Infof("Validate: %+v", action)
-			proceed := processor.handleValidationAction(action)
+			err := fmt.Errorf("Cancelled before step %d", step)
+			p.record.finish(RunCancelled, err)
+			return err
+		}
+
+		p.enterStep(step, action)
+
+		if i > 0 {
+			if reason := p.checkDeployWindow(); reason != "" {
+				return p.fail(step, action, nil, fmt.Sprintf("Deploy window closed: %s", reason))
+			}
+		}
 
-			log.Info(proceed)
-			if !proceed {
-				log.Error("Validation Failed!!")
-				processor.rollbackToLatestCheckpoint()
-				return fmt.Errorf("Failed to validate pools: rolled back")
+		if action.When != "" {
+			run, err := p.evaluateCondition(action.When)
+			if err != nil {
+				record := p.record.startStep(step, action, p.captureState())
+				return p.fail(step, action, record, fmt.Sprintf("Invalid condition %q: %s", action.When, err))
 			}
 
-		default:
-			log.Errorf("Undefined ActionType: %s", action.Type)
+			if !run {
+				p.log.Infof("Skipping step, condition not met: %s", action.When)
+				record := p.record.startStep(step, action, p.captureState())
+				p.finishStep(record, RunSkipped, fmt.Sprintf("condition not met: %s", action.When))
+				continue
+			}
 		}
+
+		p.notify(notify.StepStarted, step, action, "")
+		record := p.record.startStep(step, action, p.captureState())
+
+		status, reason := p.executeWithRetries(step, action, record)
+		switch status {
+		case RunSkipped:
+			p.finishStep(record, RunSkipped, reason)
+			continue
+
+		case RunPaused:
+			p.finishStep(record, RunPaused, reason)
+
+			paused, err := p.pause(step, action)
+			if err != nil {
+				return p.fail(step, action, nil, fmt.Sprintf("Failed to save paused run: %s", err))
+			}
+
+			if !action.Hold {
+				return nil
+			}
+
+			if reason := p.hold(paused); reason != "" {
+				return p.fail(step, action, nil, reason)
+			}
+
+			p.record.resume()
+			p.notify(notify.WorkflowResumed, step, action, "")
+			continue
+
+		case RunFailed:
+			if action.ContinueOnFailure {
+				p.log.Warnf("%s, continuing", reason)
+				p.finishStep(record, RunFailed, reason)
+				p.notify(notify.StepFailed, step, action, reason)
+				p.runOnFailure(step, action)
+				continue
+			}
+
+			return p.fail(step, action, record, reason)
+		}
+
+		p.finishStep(record, RunSucceeded, "")
+		p.notify(notify.StepSucceeded, step, action, "")
 	}
 
+	p.enterStep(0, nil)
+	p.record.finish(RunSucceeded, nil)
+	return nil
+}
+
+// wait sleeps for duration, returning false as soon as a cancel or rollback is requested
+func (p *Processor) wait(duration time.Duration) bool {
+	deadline := time.Now().Add(duration)
+	interval := p.workflow.Config.GetPollInterval(p.workflow.Service)
+
+	for {
+		if stop, _ := p.stopRequested(); stop {
+			return false
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return true
+		}
+
+		if remaining < interval {
+			time.Sleep(remaining)
+		} else {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// notifyFinished announces how the run ended, paused and blocked runs having been announced as such already
+func (p *Processor) notifyFinished(run *Run) {
+	switch run.Status {
+	case RunSucceeded:
+		p.notify(notify.WorkflowCompleted, 0, nil, "")
+	case RunPaused, RunBlocked:
+	default:
+		p.notify(notify.WorkflowFailed, 0, nil, fmt.Sprintf("%s: %s", run.Status, run.Error))
+	}
+}
+
+// executeWithRetries performs the action, retrying a failure as many times as the action allows
+func (p *Processor) executeWithRetries(step int, action *config.Action, record *StepRecord) (RunStatus, string) {
+	p.record.attempt(record)
+	status, reason := p.executeAction(step, action)
+
+	for attempt := int64(1); status == RunFailed && attempt <= action.Retries; attempt++ {
+		if stop, _ := p.stopRequested(); stop {
+			break
+		}
+
+		p.log.Warnf("%s, retrying in %s attempt: %d of %d", reason, action.RetryDelay, attempt, action.Retries)
+		time.Sleep(action.RetryDelay)
+
+		p.record.attempt(record)
+		status, reason = p.executeAction(step, action)
+	}
+
+	return status, reason
+}
+
+// executeAction performs a single action, returning why it failed
+func (p *Processor) executeAction(step int, action *config.Action) (RunStatus, string) {
+	switch action.Type {
+	case config.UpdatePool:
+		p.log.Infof("Update %s pool", action.Target)
+		if !p.handleUpdateAction(action) {
+			p.log.Error("Pool Update Failed!!")
+			return RunFailed, "Failed to update pool"
+		}
+
+	case config.TrafficShift:
+		p.log.Infof("Shift traffic to pool: %s weight: %d", action.Target, action.Ratio)
+		if !p.handleShiftAction(action) {
+			p.log.Error("Traffic Shift Failed!!")
+			return RunFailed, "Failed to shift traffic"
+		}
+
+	case config.ValidatePool:
+		p.log.Infof("Validate: %+v", action)
+		proceed := p.handleValidationAction(action)
+
+		p.log.Info(proceed)
+		if !proceed {
+			p.log.Error("Validation Failed!!")
+			return RunFailed, "Failed to validate pools"
+		}
+
+	case config.Wait:
+		p.log.Infof("Wait %s", action.Duration)
+		if !p.wait(action.Duration) {
+			return RunSkipped, "Wait ended early, stop requested"
+		}
+
+	case config.TakeCheckpoint:
+		p.takeCheckpoint(step)
+
+	case config.Pause:
+		return RunPaused, ""
+
+	default:
+		p.log.Errorf("Undefined ActionType: %s", action.Type)
+		return RunFailed, fmt.Sprintf("Unknown action: %s", action.Type)
+	}
+
+	return RunSucceeded, ""
+}
+
+// runOnFailure performs the on-failure steps of a failed action, a failing handler doesn't stop the ones after it
+func (p *Processor) runOnFailure(step int, action *config.Action) {
+	for _, handler := range action.OnFailure {
+		p.log.Infof("Running on-failure step: %s target: %s", handler.Type, handler.Target)
+		record := p.record.startStep(step, handler, p.captureState())
+
+		status, reason := p.executeWithRetries(step, handler, record)
+		p.finishStep(record, status, reason)
+	}
+}
+
+// fail reports the failed step, runs its on-failure steps and rolls back to the latest checkpoint unless the step opts out
+func (p *Processor) fail(step int, action *config.Action, record *StepRecord, reason string) error {
+	if record != nil {
+		p.finishStep(record, RunFailed, reason)
+		p.notify(notify.StepFailed, step, action, reason)
+		p.runOnFailure(step, action)
+
+		if !action.RollbackOnFailure() {
+			err := fmt.Errorf("%s: not rolled back", reason)
+			p.record.finish(RunFailed, err)
+			return err
+		}
+	}
+
+	if err := p.performRollback(step, action, reason); err != nil {
+		p.record.finish(RunRollbackFailed, err)
+		return err
+	}
+
+	err := fmt.Errorf("%s: rolled back", reason)
+	p.record.finish(RunRolledBack, err)
+	return err
+}
+
+func (p *Processor) notify(eventType notify.EventType, step int, action *config.Action, message string) {
+	p.notifier.Notify(&notify.Event{
+		Type:     eventType,
+		Service:  p.workflow.Service,
+		Workflow: p.workflow.Name,
+		Step:     step,
+		Action:   action,
+		Message:  message,
+	})
+}
+
+// performRollback rolls back to the checkpoint chosen by the rollback policy, recording and announcing it.
+// A rollback that fails is announced as such so a human can be paged
+func (p *Processor) performRollback(step int, action *config.Action, reason string) error {
+	p.mu.Lock()
+	p.rollback = false
+	p.mu.Unlock()
+
+	p.notify(notify.RollbackStarted, step, action, reason)
+	p.record.startRollback(reason, p.rollbackTarget())
+
+	err := p.rollbackToLatestCheckpoint()
+
+	p.record.finishRollback(p.captureState(), err)
+	if err != nil {
+		p.log.Errorf("[workflow.performRollback] Rollback failed: %s", err)
+		p.notify(notify.RollbackFailed, step, action, fmt.Sprintf("%s: %s", reason, err))
+		return &RollbackError{Reason: reason, Err: err}
+	}
+
+	p.notify(notify.RollbackFinished, step, action, reason)
 	return nil
 }
 
 func (p *Processor) getInitialCheckpoint() {
-	p.checkpoint.Weights = p.client.GetCurrentWeights(p.workflow.Service)
+	p.checkpoint = p.captureState()
+	p.latest = p.checkpoint
+	p.record.setCheckpoint(p.checkpoint)
+	p.record.addCheckpoint(0, p.checkpoint)
+}
+
+// takeCheckpoint records the current state as the checkpoint later failures return to
+func (p *Processor) takeCheckpoint(step int) {
+	p.latest = p.captureState()
+	p.record.addCheckpoint(step, p.latest)
+	p.log.Infof("Checkpoint: %+v", p.latest)
+}
+
+// rollbackTarget returns the checkpoint a rollback returns to under the workflows rollback policy
+func (p *Processor) rollbackTarget() *Checkpoint {
+	if p.workflow.RollbackPolicy == config.RollbackToStart {
+		return p.checkpoint
+	}
 
-	p.checkpoint.Canary = p.client.GetCurrentServiceState(p.workflow.Service, "canary")
-	p.checkpoint.Primary = p.client.GetCurrentServiceState(p.workflow.Service, "primary")
+	return p.latest
 }
 
-func (p *Processor) rollbackToLatestCheckpoint() {
-	p.client.UpdateWeights(p.workflow.Service, p.checkpoint.Weights)
+// captureState reads the current weights and the state of both pools
+func (p *Processor) captureState() *Checkpoint {
+	return &Checkpoint{
+		Weights: p.client.GetCurrentWeights(p.workflow.Service),
+		Canary:  p.client.GetCurrentServiceState(p.workflow.Service, "canary"),
+		Primary: p.client.GetCurrentServiceState(p.workflow.Service, "primary"),
+	}
+}
+
+// prepareAutoScaling records the scaling settings of auto scaled pools and applies the settings configured for the workflow
+func (p *Processor) prepareAutoScaling() error {
+	service := p.workflow.Service
+	for _, pool := range []string{"canary", "primary"} {
+		original, err := p.client.GetScalingState(service, pool)
+		if err != nil {
+			return err
+		}
+
+		if original == nil {
+			continue
+		}
+
+		p.log.Infof("[workflow.prepareAutoScaling] pool: %s is auto scaled: %+v", pool, original)
+		p.scaling[pool] = original
+
+		desired := *original
+		if p.workflow.Config.GetSuspendScaling(service) {
+			desired.DynamicScalingInSuspended = true
+			desired.DynamicScalingOutSuspended = true
+			desired.ScheduledScalingSuspended = true
+		}
+
+		if poolConfig := p.workflow.Config.GetPoolConfig(service, pool); poolConfig != nil && poolConfig.MaxCapacity > 0 {
+			desired.MinCapacity = poolConfig.MinCapacity
+			desired.MaxCapacity = poolConfig.MaxCapacity
+		}
+
+		if desired == *original {
+			continue
+		}
+
+		if err := p.client.UpdateScalingState(service, pool, &desired); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	p.client.Deploy(p.workflow.Service, "canary", p.checkpoint.Canary)
-	p.client.Deploy(p.workflow.Service, "primary", p.checkpoint.Primary)
+// restoreAutoScaling puts back the scaling settings recorded before the workflow started
+func (p *Processor) restoreAutoScaling() {
+	for pool, original := range p.scaling {
+		current, err := p.client.GetScalingState(p.workflow.Service, pool)
+		if err == nil && current != nil && *current == *original {
+			continue
+		}
 
+		if err := p.client.UpdateScalingState(p.workflow.Service, pool, original); err != nil {
+			p.log.Errorf("[workflow.restoreAutoScaling] Failed to restore auto scaling of pool: %s state: %+v", pool, original)
+		}
+	}
 }
 
+// getUpdateActionServiceState resolves the state an update action deploys without changing the workflow defaults.
+// The task and count of the action come first, then the defaults of the target pool, then the workflow defaults.
+// A count left unset everywhere keeps the current count of the pool, as does an auto scaled pool unless the action sets one
 func (p *Processor) getUpdateActionServiceState(action *config.Action) *config.ServiceState {
-	result := p.workflow.Default
+	result := &config.ServiceState{}
 
-	if action.Count != 0 {
-		result.Count = action.Count
+	layers := []*config.ServiceState{
+		{TaskDef: action.Task, Count: action.Count},
+		p.workflow.PoolDefaults[action.Target],
+		p.workflow.Default,
 	}
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
 
-	if action.Task != "" {
-		result.TaskDef = action.Task
+		if result.TaskDef == "" {
+			result.TaskDef = layer.TaskDef
+		}
+
+		if result.Count == 0 {
+			result.Count = layer.Count
+		}
 	}
 
-	return result
+	if result.Count == 0 || (p.scaling[action.Target] != nil && action.Count == 0) {
+		if current := p.client.GetCurrentServiceState(p.workflow.Service, action.Target); current != nil {
+			result.Count = current.Count
+		}
+	}
 
+	return result
 }
 
 func (p *Processor) handleUpdateAction(action *config.Action) bool {
@@ -121,9 +742,172 @@ func (p *Processor) handleShiftAction(action *config.Action) bool {
 		weights.Canary = 100 - action.Ratio
 	}
 
+	current := p.client.GetCurrentWeights(p.workflow.Service)
+	receiving := receivingPools(current, weights)
+	check := p.workflow.Config.GetHealthCheck(p.workflow.Service)
+
+	var reference int64
+	if action.PreScale || action.ScaleDown {
+		var err error
+		if reference, err = p.getFullTrafficCount(); err != nil {
+			p.log.Errorf("[workflow.handleShiftAction] %s", err)
+			return false
+		}
+	}
+
+	if action.PreScale {
+		for _, pool := range receiving {
+			if !p.scalePool(pool, requiredCount(reference, poolWeight(weights, pool)), true) {
+				p.log.Errorf("[workflow.handleShiftAction] Failed to pre-scale pool: %s", pool)
+				return false
+			}
+		}
+	}
+
+	for _, pool := range receiving {
+		if !p.isPoolHealthy(pool, check) {
+			p.log.Errorf("[workflow.handleShiftAction] Refusing to shift traffic to unhealthy pool: %s", pool)
+			return false
+		}
+	}
+
 	err := p.client.UpdateWeights(p.workflow.Service, weights)
+	if err != nil {
+		return false
+	}
 
-	return err == nil
+	if !p.waitForShiftToSettle(receiving, check) {
+		return false
+	}
+
+	if action.ScaleDown {
+		for _, pool := range losingPools(current, weights) {
+			if !p.scalePool(pool, requiredCount(reference, poolWeight(weights, pool)), false) {
+				p.log.Errorf("[workflow.handleShiftAction] Failed to scale down pool: %s", pool)
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// getFullTrafficCount returns the number of tasks needed to serve all traffic, the larger of the two pools when the
+// workflow started. Taking it from the start keeps a scale-down ramp from shrinking the reference with every step
+func (p *Processor) getFullTrafficCount() (int64, error) {
+	state := p.checkpoint
+	if state == nil || state.Canary == nil || state.Primary == nil {
+		state = p.captureState()
+	}
+
+	if state.Canary == nil || state.Primary == nil {
+		return 0, fmt.Errorf("failed to read the task counts of the pools")
+	}
+
+	if state.Canary.Count > state.Primary.Count {
+		return state.Canary.Count, nil
+	}
+
+	return state.Primary.Count, nil
+}
+
+// scalePool scales the pool to count, only growing it when up is true and only shrinking it otherwise
+func (p *Processor) scalePool(pool string, count int64, up bool) bool {
+	current := p.client.GetCurrentServiceState(p.workflow.Service, pool)
+	if current == nil {
+		p.log.Errorf("[workflow.scalePool] Failed to read the current state of pool: %s", pool)
+		return false
+	}
+
+	if (up && current.Count >= count) || (!up && current.Count <= count) {
+		return true
+	}
+
+	p.log.Infof("[workflow.scalePool] Scaling pool: %s from %d to %d tasks", pool, current.Count, count)
+	return p.client.Scale(p.workflow.Service, pool, count)
+}
+
+// requiredCount returns the number of tasks needed to serve weight percent of the traffic served by reference tasks
+func requiredCount(reference, weight int64) int64 {
+	if weight <= 0 {
+		return 0
+	}
+
+	count := (reference*weight + 99) / 100
+	if count < 1 {
+		return 1
+	}
+
+	return count
+}
+
+func poolWeight(weights *config.ServiceWeights, pool string) int64 {
+	if pool == "canary" {
+		return weights.Canary
+	}
+
+	return weights.Primary
+}
+
+// losingPools returns the pools whose share of traffic shrinks from current to next
+func losingPools(current, next *config.ServiceWeights) []string {
+	pools := []string{}
+	if current == nil {
+		return pools
+	}
+
+	if next.Canary < current.Canary {
+		pools = append(pools, "canary")
+	}
+
+	if next.Primary < current.Primary {
+		pools = append(pools, "primary")
+	}
+
+	return pools
+}
+
+// receivingPools returns the pools whose share of traffic grows from current to next
+func receivingPools(current, next *config.ServiceWeights) []string {
+	pools := []string{}
+	if next.Canary > 0 && (current == nil || next.Canary > current.Canary) {
+		pools = append(pools, "canary")
+	}
+
+	if next.Primary > 0 && (current == nil || next.Primary > current.Primary) {
+		pools = append(pools, "primary")
+	}
+
+	return pools
+}
+
+func (p *Processor) isPoolHealthy(pool string, check *config.HealthCheck) bool {
+	health, err := p.client.GetTargetHealth(p.workflow.Service, pool)
+	if err != nil {
+		return false
+	}
+
+	p.log.Infof("[workflow.isPoolHealthy] pool: %s healthy: %d total: %d", pool, health.Healthy, health.Total)
+	return check.IsHealthy(health)
+}
+
+// waitForShiftToSettle re-checks the receiving pools until the settle period has passed
+func (p *Processor) waitForShiftToSettle(pools []string, check *config.HealthCheck) bool {
+	deadline := time.Now().Add(check.SettlePeriod)
+	for {
+		for _, pool := range pools {
+			if !p.isPoolHealthy(pool, check) {
+				p.log.Errorf("[workflow.waitForShiftToSettle] Pool became unhealthy after the traffic shift: %s", pool)
+				return false
+			}
+		}
+
+		if !time.Now().Before(deadline) {
+			return true
+		}
+
+		time.Sleep(check.Interval)
+	}
 }
 
 func (p *Processor) handleValidationAction(action *config.Action) bool {
@@ -134,20 +918,116 @@ func (p *Processor) handleValidationAction(action *config.Action) bool {
 		fmt.Println("Does the current system state pass validation (y/n)? ")
 		answer, err := reader.ReadString('\n')
 		if err != nil {
-			log.Error(err)
+			p.log.Error(err)
 		}
 
 		if strings.ToLower(strings.Trim(answer, "\n")) == "y" {
-			log.Info("Continue")
+			p.log.Info("Continue")
+			p.result = "prompt: approved"
 			return true
 		}
 
-		log.Info("Cancel")
+		p.log.Info("Cancel")
+		p.result = "prompt: rejected"
 		return false
 
+	case "approval":
+		return p.handleApproval(action)
+
 	case "task":
-		return !p.client.StartAndMonitorTask(p.workflow.Service, p.client.Config.GetServiceValidationTask(p.workflow.Service), p.client.Config.Services[p.workflow.Service].ValidationTaskContainer, action.Command)
+		failed := p.client.StartAndMonitorTask(p.workflow.Service, p.workflow.Config.GetServiceValidationTask(p.workflow.Service), p.workflow.Config.Services[p.workflow.Service].ValidationTaskContainer, action.Command)
+		if failed {
+			p.result = "task: failed"
+		} else {
+			p.result = "task: passed"
+		}
+
+		return !failed
 	default:
+		p.result = fmt.Sprintf("unknown validator: %s", action.Target)
+		return false
+	}
+}
+
+// handleApproval asks the configured approvers to approve the current state and waits for their decision, denying it on timeout
+func (p *Processor) handleApproval(action *config.Action) bool {
+	approvalConfig := p.workflow.Config.GetApprovalConfig(p.workflow.Service)
+
+	request, err := approval.NewRequest(fmt.Sprintf("%s %s", p.workflow.Service, p.workflow.Name), approvalConfig.Approvers)
+	if err != nil {
+		p.log.Errorf("[workflow.handleApproval] Failed to create approval request: %s", err)
+		return false
+	}
+
+	gate := approval.NewGate(approvalConfig.Approvers, approvalConfig.Required)
+
+	if approvalConfig.Listen != "" {
+		listener := approval.NewListener(approvalConfig.Listen, request, gate)
+		if err := listener.Start(); err != nil {
+			p.log.Errorf("[workflow.handleApproval] Failed to start approval listener: %s", err)
+			return false
+		}
+		defer listener.Close()
+	}
+
+	var store approval.Store
+	if approvalConfig.StoreDir != "" {
+		store = &approval.FileStore{Dir: approvalConfig.StoreDir}
+	}
+
+	if store == nil && approvalConfig.Listen == "" {
+		p.log.Error("[workflow.handleApproval] approval requires a listen address or a store-dir")
 		return false
 	}
+
+	notifier := p.notifier
+	if len(approvalConfig.Notifiers) > 0 {
+		notifier, err = notify.NewForNotifiers(p.workflow.Config, approvalConfig.Notifiers)
+		if err != nil {
+			p.log.Errorf("[workflow.handleApproval] Failed to configure approval notifiers: %s", err)
+			return false
+		}
+	}
+
+	message := fmt.Sprintf("Approval requested: %s", request.ID)
+	if approvalConfig.CallbackURL != "" {
+		approvers := request.Approvers()
+		if len(approvers) == 0 {
+			message = fmt.Sprintf("%s decide: %s", message, request.Link(approvalConfig.CallbackURL, ""))
+		}
+		for _, approver := range approvers {
+			message = fmt.Sprintf("%s %s: %s", message, approver, request.Link(approvalConfig.CallbackURL, approver))
+		}
+	}
+	notifier.Notify(&notify.Event{
+		Type:     notify.ApprovalRequested,
+		Service:  p.workflow.Service,
+		Workflow: p.workflow.Name,
+		Action:   action,
+		Message:  message,
+	})
+	p.log.Infof("[workflow.handleApproval] %s", message)
+
+	result := approval.Await(request, gate, store, approvalConfig.Timeout, approvalConfig.PollInterval)
+
+	switch {
+	case result.Approved:
+		message = fmt.Sprintf("Approved by %s", strings.Join(result.Approvers, ", "))
+	case result.TimedOut:
+		message = fmt.Sprintf("Denied: no decision within %s", approvalConfig.Timeout)
+	default:
+		message = fmt.Sprintf("Rejected by %s", result.RejectedBy)
+	}
+
+	p.log.Infof("[workflow.handleApproval] request: %s %s", request.ID, message)
+	p.result = fmt.Sprintf("approval: %s", message)
+	notifier.Notify(&notify.Event{
+		Type:     notify.ApprovalDecided,
+		Service:  p.workflow.Service,
+		Workflow: p.workflow.Name,
+		Action:   action,
+		Message:  message,
+	})
+
+	return result.Approved
 }
@@ -0,0 +1,396 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/notify"
+)
+
+func TestProcessor_rollbackTarget(t *testing.T) {
+	start := &Checkpoint{Weights: &config.ServiceWeights{Primary: 100}}
+	latest := &Checkpoint{Weights: &config.ServiceWeights{Canary: 10, Primary: 90}}
+
+	tests := []struct {
+		name   string
+		policy config.RollbackPolicy
+		want   *Checkpoint
+	}{
+		{name: "to_last_checkpoint", policy: config.RollbackToLastCheckpoint, want: latest},
+		{name: "to_start", policy: config.RollbackToStart, want: start},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Processor{
+				workflow:   &config.Workflow{RollbackPolicy: tt.policy},
+				checkpoint: start,
+				latest:     latest,
+			}
+
+			if got := p.rollbackTarget(); got != tt.want {
+				t.Errorf("rollbackTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProcessor_rollbackPolicy(t *testing.T) {
+	_, err := NewProcessor(&config.Workflow{
+		Config:         &config.Config{},
+		RollbackPolicy: "to-yesterday",
+	})
+	if err == nil {
+		t.Error("NewProcessor() error = nil, want unknown rollback policy")
+	}
+}
+
+func TestProcessor_changedPools(t *testing.T) {
+	target := &Checkpoint{
+		Weights: &config.ServiceWeights{Primary: 100},
+		Canary:  &config.ServiceState{TaskDef: "app:1", Count: 1},
+		Primary: &config.ServiceState{TaskDef: "app:1", Count: 4},
+	}
+
+	tests := []struct {
+		name    string
+		current *Checkpoint
+		scaling map[string]*config.ScalingState
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			current: target,
+			want:    []string{},
+		},
+		{
+			name: "canary_task_def",
+			current: &Checkpoint{
+				Weights: &config.ServiceWeights{Canary: 20, Primary: 80},
+				Canary:  &config.ServiceState{TaskDef: "app:2", Count: 1},
+				Primary: &config.ServiceState{TaskDef: "app:1", Count: 4},
+			},
+			want: []string{"canary"},
+		},
+		{
+			name: "least_traffic_first",
+			current: &Checkpoint{
+				Weights: &config.ServiceWeights{Canary: 90, Primary: 10},
+				Canary:  &config.ServiceState{TaskDef: "app:2", Count: 4},
+				Primary: &config.ServiceState{TaskDef: "app:2", Count: 4},
+			},
+			want: []string{"primary", "canary"},
+		},
+		{
+			name: "auto_scaled_count",
+			current: &Checkpoint{
+				Weights: &config.ServiceWeights{Primary: 100},
+				Canary:  &config.ServiceState{TaskDef: "app:1", Count: 1},
+				Primary: &config.ServiceState{TaskDef: "app:1", Count: 6},
+			},
+			scaling: map[string]*config.ScalingState{"primary": {}},
+			want:    []string{},
+		},
+		{
+			name: "count",
+			current: &Checkpoint{
+				Weights: &config.ServiceWeights{Primary: 100},
+				Canary:  &config.ServiceState{TaskDef: "app:1", Count: 1},
+				Primary: &config.ServiceState{TaskDef: "app:1", Count: 6},
+			},
+			want: []string{"primary"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Processor{scaling: tt.scaling}
+
+			if got := p.changedPools(target, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changedPools() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackError(t *testing.T) {
+	cause := fmt.Errorf("deployment failed")
+	var err error = &RollbackError{Reason: "Health check failed", Err: cause}
+
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is(%v, %v) = false, want true", err, cause)
+	}
+
+	if got, want := err.Error(), "Health check failed: rollback failed: deployment failed"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessor_rollbackToLatestCheckpoint_drain(t *testing.T) {
+	client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:2", Count: 10})
+	p := newTestProcessor(&config.Workflow{}, client)
+	p.checkpoint = &Checkpoint{
+		Weights: &config.ServiceWeights{Primary: 100},
+		Canary:  &config.ServiceState{TaskDef: "app:1", Count: 1},
+		Primary: &config.ServiceState{TaskDef: "app:1", Count: 10},
+	}
+	p.latest = p.checkpoint
+
+	if err := p.rollbackToLatestCheckpoint(); err != nil {
+		t.Fatalf("rollbackToLatestCheckpoint() error = %v", err)
+	}
+
+	wantScaled := []*deployment{
+		{pool: "canary", state: config.ServiceState{Count: 10}},
+		{pool: "canary", state: config.ServiceState{Count: 1}},
+	}
+	if !reflect.DeepEqual(client.scaled, wantScaled) {
+		t.Errorf("rollbackToLatestCheckpoint() scaled %d times, want the canary scaled up to drain onto and back", len(client.scaled))
+	}
+
+	wantDeployed := []*deployment{{pool: "primary", state: config.ServiceState{TaskDef: "app:1", Count: 10}}}
+	if !reflect.DeepEqual(client.deployed, wantDeployed) {
+		t.Errorf("rollbackToLatestCheckpoint() deployed %d times, want only the primary redeployed", len(client.deployed))
+	}
+
+	if *client.weights != *p.checkpoint.Weights {
+		t.Errorf("rollbackToLatestCheckpoint() weights = %+v, want %+v", client.weights, p.checkpoint.Weights)
+	}
+}
+
+func TestProcessor_Rollback_duringLastStep(t *testing.T) {
+	client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 4})
+	p := newTestProcessor(&config.Workflow{
+		Default: &config.ServiceState{TaskDef: "app:2"},
+		Steps:   []*config.Action{{Type: config.UpdatePool, Target: "canary"}},
+	}, client)
+
+	client.onDeploy = func() {
+		client.onDeploy = nil
+		if err := p.Rollback(); err != nil {
+			t.Errorf("Rollback() error = %v", err)
+		}
+	}
+
+	if err := p.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if run := p.Status(); run.Status != RunRolledBack {
+		t.Errorf("Run() status = %s, want %s", run.Status, RunRolledBack)
+	}
+
+	if canary := client.pools["canary"]; canary.TaskDef != "app:1" {
+		t.Errorf("Run() canary = %+v, want app:1", canary)
+	}
+}
+
+func TestProcessor_Rollback_inProgress(t *testing.T) {
+	p := &Processor{finished: true, rollingBack: true}
+
+	if err := p.Rollback(); err == nil {
+		t.Error("Rollback() error = nil, want an error while a rollback is in progress")
+	}
+}
+
+func TestProcessor_Run_failureHandling(t *testing.T) {
+	noRollback := false
+	restorePrimary := []*config.Action{{Type: config.TrafficShift, Target: "primary", Ratio: 100}}
+
+	tests := []struct {
+		name         string
+		update       config.Action
+		failDeploys  int
+		want         RunStatus
+		wantAttempts int
+		wantWeights  config.ServiceWeights
+		wantCanary   string
+	}{
+		{
+			name:         "retried",
+			update:       config.Action{Retries: 2},
+			failDeploys:  2,
+			want:         RunSucceeded,
+			wantAttempts: 3,
+			wantWeights:  config.ServiceWeights{Canary: 20, Primary: 80},
+			wantCanary:   "app:2",
+		},
+		{
+			name:         "retries_exhausted",
+			update:       config.Action{Retries: 1},
+			failDeploys:  2,
+			want:         RunRolledBack,
+			wantAttempts: 2,
+			wantWeights:  config.ServiceWeights{Primary: 100},
+			wantCanary:   "app:1",
+		},
+		{
+			name:         "on_failure_without_rollback",
+			update:       config.Action{Rollback: &noRollback, OnFailure: restorePrimary},
+			failDeploys:  1,
+			want:         RunFailed,
+			wantAttempts: 1,
+			wantWeights:  config.ServiceWeights{Primary: 100},
+			wantCanary:   "app:1",
+		},
+		{
+			name:         "continue_on_failure",
+			update:       config.Action{ContinueOnFailure: true, OnFailure: restorePrimary},
+			failDeploys:  1,
+			want:         RunSucceeded,
+			wantAttempts: 1,
+			wantWeights:  config.ServiceWeights{Primary: 100},
+			wantCanary:   "app:1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 4}, config.ServiceState{TaskDef: "app:1", Count: 4})
+			client.failDeploys = tt.failDeploys
+
+			update := tt.update
+			update.Type = config.UpdatePool
+			update.Target = "canary"
+
+			p := newTestProcessor(&config.Workflow{
+				Default: &config.ServiceState{TaskDef: "app:2", Count: 4},
+				Steps: []*config.Action{
+					{Type: config.TrafficShift, Target: "canary", Ratio: 20},
+					&update,
+				},
+			}, client)
+
+			p.Run()
+
+			run := p.Status()
+			if run.Status != tt.want {
+				t.Fatalf("Run() status = %s error: %s, want %s", run.Status, run.Error, tt.want)
+			}
+
+			if got := run.Steps[1].Attempts; got != tt.wantAttempts {
+				t.Errorf("Run() attempts = %d, want %d", got, tt.wantAttempts)
+			}
+
+			if *client.weights != tt.wantWeights {
+				t.Errorf("Run() weights = %+v, want %+v", client.weights, tt.wantWeights)
+			}
+
+			if got := client.pools["canary"].TaskDef; got != tt.wantCanary {
+				t.Errorf("Run() canary = %s, want %s", got, tt.wantCanary)
+			}
+		})
+	}
+}
+
+func TestProcessor_Run_terminalEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		failDeploys int
+		scalingErr  error
+		want        notify.EventType
+	}{
+		{name: "completed", want: notify.WorkflowCompleted},
+		{name: "rolled_back", failDeploys: 1, want: notify.WorkflowFailed},
+		{name: "auto_scaling_unreadable", scalingErr: fmt.Errorf("throttled"), want: notify.WorkflowFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []notify.EventType{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				event := &notify.Event{}
+				json.NewDecoder(r.Body).Decode(event)
+				events = append(events, event.Type)
+			}))
+			defer server.Close()
+
+			client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 4})
+			client.failDeploys = tt.failDeploys
+			client.scalingErr = tt.scalingErr
+
+			p := newTestProcessor(&config.Workflow{
+				Default: &config.ServiceState{TaskDef: "app:2"},
+				Steps:   []*config.Action{{Type: config.UpdatePool, Target: "canary"}},
+			}, client)
+
+			p.workflow.Config.Notifiers = map[string]*config.NotifierConfig{"hook": {Type: "webhook", URL: server.URL}}
+			notifier, err := notify.New(p.workflow.Config, "app")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.notifier = notifier
+
+			p.Run()
+
+			if len(events) == 0 || events[0] != notify.WorkflowStarted || events[len(events)-1] != tt.want {
+				t.Errorf("Run() events = %v, want %s first and %s last", events, notify.WorkflowStarted, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessor_Run_waitInterrupted(t *testing.T) {
+	client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 4})
+	p := newTestProcessor(&config.Workflow{
+		Default: &config.ServiceState{TaskDef: "app:2"},
+		Steps: []*config.Action{
+			{Type: config.UpdatePool, Target: "canary"},
+			{Type: config.Wait, Duration: time.Hour},
+			{Type: config.TrafficShift, Target: "canary", Ratio: 10},
+		},
+	}, client)
+	p.workflow.Config.Services["app"].PollInterval = time.Millisecond
+
+	// ask for the rollback once the run is waiting
+	client.onDeploy = func() {
+		client.onDeploy = nil
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			p.Rollback()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not end the wait once a rollback was requested")
+	}
+
+	if run := p.Status(); run.Status != RunRolledBack {
+		t.Errorf("Run() status = %s, want %s", run.Status, RunRolledBack)
+	}
+
+	if client.weights.Canary != 0 || client.pools["canary"].TaskDef != "app:1" {
+		t.Errorf("Run() weights = %+v canary: %+v, want the shift skipped and the canary rolled back", client.weights, client.pools["canary"])
+	}
+}
+
+func TestProcessor_Run_unknownAction(t *testing.T) {
+	client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 4})
+	p := newTestProcessor(&config.Workflow{
+		Default: &config.ServiceState{TaskDef: "app:2"},
+		Steps: []*config.Action{
+			{Type: config.UpdatePool, Target: "canary"},
+			{Type: "traffic-shift", Target: "canary", Ratio: 10},
+		},
+	}, client)
+
+	if err := p.Run(); err == nil {
+		t.Errorf("Run() error = nil, want the unknown action to fail the run")
+	}
+
+	run := p.Status()
+	if run.Status != RunRolledBack || client.pools["canary"].TaskDef != "app:1" {
+		t.Errorf("Run() status = %s canary: %+v, want the update rolled back", run.Status, client.pools["canary"])
+	}
+}
@@ -0,0 +1,82 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// evaluateCondition checks a when condition against the current state of the service
+func (p *Processor) evaluateCondition(condition string) (bool, error) {
+	return evaluateCondition(condition, conditionValues(p.captureState()))
+}
+
+// conditionValues the values a condition may refer to by name
+func conditionValues(state *Checkpoint) map[string]string {
+	values := map[string]string{}
+	if state.Weights != nil {
+		values["canary-weight"] = strconv.FormatInt(state.Weights.Canary, 10)
+		values["primary-weight"] = strconv.FormatInt(state.Weights.Primary, 10)
+	}
+
+	if state.Canary != nil {
+		values["canary-count"] = strconv.FormatInt(state.Canary.Count, 10)
+		values["canary-task-def"] = state.Canary.TaskDef
+	}
+
+	if state.Primary != nil {
+		values["primary-count"] = strconv.FormatInt(state.Primary.Count, 10)
+		values["primary-task-def"] = state.Primary.TaskDef
+	}
+
+	return values
+}
+
+// evaluateCondition evaluates "left operator right" where either side is a value name or a literal. Numbers are
+// compared numerically with any operator, anything else only supports == and !=
+func evaluateCondition(condition string, values map[string]string) (bool, error) {
+	parts := strings.Fields(condition)
+	if len(parts) != 3 {
+		return false, fmt.Errorf("expected <value> <operator> <value>")
+	}
+
+	left, operator, right := resolveOperand(parts[0], values), parts[1], resolveOperand(parts[2], values)
+
+	leftNumber, leftErr := strconv.ParseInt(left, 10, 64)
+	rightNumber, rightErr := strconv.ParseInt(right, 10, 64)
+	if leftErr == nil && rightErr == nil {
+		switch operator {
+		case "==":
+			return leftNumber == rightNumber, nil
+		case "!=":
+			return leftNumber != rightNumber, nil
+		case ">":
+			return leftNumber > rightNumber, nil
+		case ">=":
+			return leftNumber >= rightNumber, nil
+		case "<":
+			return leftNumber < rightNumber, nil
+		case "<=":
+			return leftNumber <= rightNumber, nil
+		}
+
+		return false, fmt.Errorf("unknown operator %s", operator)
+	}
+
+	switch operator {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+
+	return false, fmt.Errorf("operator %s needs numbers, got %s and %s", operator, left, right)
+}
+
+func resolveOperand(operand string, values map[string]string) string {
+	if value, ok := values[operand]; ok {
+		return value
+	}
+
+	return strings.Trim(operand, `"'`)
+}
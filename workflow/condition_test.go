@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+func Test_evaluateCondition(t *testing.T) {
+	values := conditionValues(&Checkpoint{
+		Weights: &config.ServiceWeights{Canary: 10, Primary: 90},
+		Canary:  &config.ServiceState{TaskDef: "app:5", Count: 1},
+		Primary: &config.ServiceState{TaskDef: "app:4", Count: 4},
+	})
+
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+		wantErr   bool
+	}{
+		{name: "weight_greater", condition: "canary-weight > 0", want: true},
+		{name: "weight_equal", condition: "primary-weight == 100", want: false},
+		{name: "count_less_equal", condition: "canary-count <= primary-count", want: true},
+		{name: "task_def_differs", condition: "canary-task-def != primary-task-def", want: true},
+		{name: "literal", condition: `"true" == true`, want: true},
+		{name: "string_ordering", condition: "canary-task-def > primary-task-def", wantErr: true},
+		{name: "unknown_operator", condition: "canary-weight ~ 1", wantErr: true},
+		{name: "malformed", condition: "canary-weight>0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluateCondition(tt.condition, values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evaluateCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("evaluateCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
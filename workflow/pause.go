@@ -0,0 +1,262 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/notify"
+)
+
+// Continue resumes a paused run at the step after its pause. A held run is asked to continue by the process holding
+// it and a run past its deadline is aborted instead. The run stays paused while the service is frozen or outside of
+// its deploy windows unless overrideReason says why they are overridden. Only the first continue or abort of a pause
+// resumes it, the others fail
+func Continue(cfg *config.Config, id, overrideReason string) error {
+	store := NewStateStore(cfg)
+	paused, err := loadPausedRun(store, id)
+	if err != nil {
+		return err
+	}
+
+	if paused.Expired(time.Now()) {
+		return abortPausedRun(cfg, store, paused, "Auto-abort deadline passed")
+	}
+
+	if overrideReason == "" {
+		overrideReason = paused.Run.OverrideReason
+	}
+
+	if err := cfg.CheckDeployWindow(paused.Run.Service, time.Now()); err != nil && overrideReason == "" {
+		return fmt.Errorf("run %s remains paused: %s", id, err)
+	}
+
+	if paused.Hold {
+		paused.Decision = ContinueRun
+		paused.OverrideReason = overrideReason
+		return store.Save(paused)
+	}
+
+	paused.Run.OverrideReason = overrideReason
+	p, err := resumeProcessor(cfg, paused)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Claim(paused); err != nil {
+		return err
+	}
+
+	return p.Run()
+}
+
+// Abort rolls a paused run back to the checkpoint chosen by its rollback policy. A held run is asked to abort by the
+// process holding it
+func Abort(cfg *config.Config, id, reason string) error {
+	store := NewStateStore(cfg)
+	paused, err := loadPausedRun(store, id)
+	if err != nil {
+		return err
+	}
+
+	return abortPausedRun(cfg, store, paused, reason)
+}
+
+// AbortExpired aborts every paused run past its deadline, returning the ids of the runs it aborted
+func AbortExpired(cfg *config.Config) ([]string, error) {
+	store := NewStateStore(cfg)
+	runs, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	aborted := []string{}
+	for _, paused := range runs {
+		if !paused.Expired(time.Now()) {
+			continue
+		}
+
+		if err := abortPausedRun(cfg, store, paused, "Auto-abort deadline passed"); err != nil {
+			return aborted, fmt.Errorf("failed to abort run %s: %w", paused.Run.ID, err)
+		}
+		aborted = append(aborted, paused.Run.ID)
+	}
+
+	return aborted, nil
+}
+
+func loadPausedRun(store StateStore, id string) (*PausedRun, error) {
+	paused, err := store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if paused == nil {
+		return nil, fmt.Errorf("no paused run with id %s", id)
+	}
+
+	return paused, nil
+}
+
+// abortPausedRun rolls the paused run back. A held run, even one past its deadline, is only marked aborted so the
+// process holding it rolls it back rather than both doing so at once
+func abortPausedRun(cfg *config.Config, store StateStore, paused *PausedRun, reason string) error {
+	if paused.Hold {
+		paused.Decision = AbortRun
+		paused.Reason = reason
+		return store.Save(paused)
+	}
+
+	p, err := resumeProcessor(cfg, paused)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Claim(paused); err != nil {
+		return err
+	}
+
+	return p.abort(reason)
+}
+
+// resumeProcessor recreates the processor of a paused run, picking up at the step after the pause
+func resumeProcessor(cfg *config.Config, paused *PausedRun) (*Processor, error) {
+	run := paused.Run
+	if cfg.Services[run.Service] == nil {
+		return nil, fmt.Errorf("service %s of run %s is not configured", run.Service, run.ID)
+	}
+
+	p, err := newProcessor(&config.Workflow{
+		Config:         cfg,
+		Service:        run.Service,
+		Name:           run.Workflow,
+		Parameters:     run.Parameters,
+		RollbackPolicy: run.RollbackPolicy,
+		OverrideReason: run.OverrideReason,
+		Default:        paused.Default,
+		PoolDefaults:   paused.PoolDefaults,
+		Steps:          paused.Steps,
+	}, run)
+	if err != nil {
+		return nil, err
+	}
+
+	p.checkpoint = run.Checkpoint
+	p.latest = run.Checkpoint
+	if len(run.Checkpoints) > 0 {
+		p.latest = run.Checkpoints[len(run.Checkpoints)-1].State
+	}
+	p.next = paused.Next
+	p.resumed = true
+
+	return p, nil
+}
+
+// pause saves the run so it can be resumed at the step after the pause
+func (p *Processor) pause(step int, action *config.Action) (*PausedRun, error) {
+	p.record.pause()
+
+	paused := &PausedRun{
+		Default:      p.workflow.Default,
+		PoolDefaults: p.workflow.PoolDefaults,
+		Steps:        p.workflow.Steps,
+		Next:         step,
+		Hold:         action.Hold,
+		PausedAt:     time.Now(),
+		Run:          p.record.snapshot(),
+	}
+	if action.AbortAfter > 0 {
+		paused.Deadline = paused.PausedAt.Add(action.AbortAfter)
+	}
+
+	if err := p.state.Save(paused); err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("continue with: pompeii continue %s, or roll back with: pompeii abort %s", paused.Run.ID, paused.Run.ID)
+	if !paused.Deadline.IsZero() {
+		message = fmt.Sprintf("%s, aborted after %s", message, paused.Deadline.Format(time.RFC3339))
+	}
+	p.log.Infof("Paused, %s", message)
+	p.notify(notify.WorkflowPaused, step, action, message)
+
+	return paused, nil
+}
+
+// hold waits for a held run to be continued or aborted, returning why it was aborted or "" once it may continue.
+// A cancel or rollback of the running workflow ends the wait and is handled before the next step
+func (p *Processor) hold(paused *PausedRun) string {
+	id := paused.Run.ID
+	interval := p.workflow.Config.GetPollInterval(p.workflow.Service)
+
+	for {
+		if stop, _ := p.stopRequested(); stop {
+			p.deletePausedRun(id)
+			return ""
+		}
+
+		latest, err := p.state.Load(id)
+		switch {
+		case err != nil:
+			p.log.Warnf("[workflow.hold] Failed to read paused run: %s", err)
+		case latest == nil:
+			return "Paused run was removed"
+		case latest.Decision == ContinueRun:
+			p.deletePausedRun(id)
+			if latest.OverrideReason != "" {
+				p.workflow.OverrideReason = latest.OverrideReason
+				p.record.setOverrideReason(latest.OverrideReason)
+			}
+			return ""
+		case latest.Decision == AbortRun:
+			p.deletePausedRun(id)
+			return latest.Reason
+		}
+
+		if paused.Expired(time.Now()) {
+			p.deletePausedRun(id)
+			return "Auto-abort deadline passed"
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (p *Processor) deletePausedRun(id string) {
+	if err := p.state.Delete(id); err != nil {
+		p.log.Errorf("[workflow.deletePausedRun] Failed to delete paused run: %s", err)
+	}
+}
+
+// abort rolls a resumed run back without running any further steps
+func (p *Processor) abort(reason string) error {
+	p.record.resume()
+	p.notify(notify.WorkflowResumed, p.next, nil, reason)
+
+	err := p.rollbackPausedRun(reason)
+
+	if p.markFinished() {
+		if rollbackErr := p.rollbackFinished(); rollbackErr != nil {
+			err = rollbackErr
+		}
+	}
+
+	run := p.record.snapshot()
+	p.notifyFinished(run)
+	final := p.captureState()
+	p.pushMetrics(run, final)
+	p.appendAuditRecord(run, final)
+
+	return err
+}
+
+func (p *Processor) rollbackPausedRun(reason string) error {
+	defer p.restoreAutoScaling()
+	if err := p.prepareAutoScaling(); err != nil {
+		err = fmt.Errorf("Failed to prepare auto scaling: %s", err)
+		p.record.finish(RunFailed, err)
+		return err
+	}
+
+	return p.fail(p.next, nil, nil, reason)
+}
@@ -0,0 +1,201 @@
+package workflow
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+func newTestStateStore(t *testing.T) (*LocalStateStore, func()) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &LocalStateStore{Dir: dir}, func() { os.RemoveAll(dir) }
+}
+
+func TestProcessor_pause(t *testing.T) {
+	store, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 4})
+	p := newTestProcessor(&config.Workflow{
+		Default: &config.ServiceState{TaskDef: "app:2"},
+		Steps: []*config.Action{
+			{Type: config.UpdatePool, Target: "canary"},
+			{Type: config.TrafficShift, Target: "canary", Ratio: 10},
+			{Type: config.Pause, AbortAfter: time.Hour},
+			{Type: config.TrafficShift, Target: "canary", Ratio: 100},
+			{Type: config.UpdatePool, Target: "primary"},
+		},
+	}, client)
+	p.state = store
+
+	if err := p.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	run := p.Status()
+	if run.Status != RunPaused || len(client.deployed) != 1 || client.weights.Canary != 10 {
+		t.Fatalf("Run() status = %s deployed: %d weights: %+v, want paused at 10%% canary", run.Status, len(client.deployed), client.weights)
+	}
+
+	paused, err := store.Load(run.ID)
+	if err != nil || paused == nil {
+		t.Fatalf("Load() = %+v, %v, want the paused run", paused, err)
+	}
+
+	if paused.Next != 3 || paused.Deadline.Sub(paused.PausedAt) != time.Hour {
+		t.Errorf("Load() next = %d deadline: %s, want 3 and an hour after the pause", paused.Next, paused.Deadline)
+	}
+
+	tests := []struct {
+		name    string
+		abort   bool
+		want    RunStatus
+		weights config.ServiceWeights
+		canary  config.ServiceState
+		primary config.ServiceState
+	}{
+		{
+			name:    "continue",
+			want:    RunSucceeded,
+			weights: config.ServiceWeights{Canary: 100},
+			canary:  config.ServiceState{TaskDef: "app:2", Count: 1},
+			primary: config.ServiceState{TaskDef: "app:2", Count: 4},
+		},
+		{
+			name:    "abort",
+			abort:   true,
+			want:    RunRolledBack,
+			weights: config.ServiceWeights{Primary: 100},
+			canary:  config.ServiceState{TaskDef: "app:1", Count: 1},
+			primary: config.ServiceState{TaskDef: "app:1", Count: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resumedClient := newFakeClient(*client.pools["canary"], *client.pools["primary"])
+			resumedClient.weights = &config.ServiceWeights{Canary: 10, Primary: 90}
+
+			pushes := []string{}
+			gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pushes = append(pushes, r.URL.Path)
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer gateway.Close()
+
+			cfg := &config.Config{
+				Services: map[string]*config.ServiceConfig{"app": {}},
+				Metrics:  &config.MetricsConfig{PushgatewayURL: gateway.URL},
+			}
+			resumed, err := resumeProcessor(cfg, paused)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resumed.client = resumedClient
+			resumed.state = store
+
+			if tt.abort {
+				err = resumed.abort("Aborted")
+			} else {
+				err = resumed.Run()
+			}
+
+			if got := resumed.Status(); got.ID != run.ID || got.Status != tt.want {
+				t.Errorf("resumed run %s status = %s error: %v, want %s %s", got.ID, got.Status, err, run.ID, tt.want)
+			}
+
+			if *resumedClient.weights != tt.weights || *resumedClient.pools["canary"] != tt.canary || *resumedClient.pools["primary"] != tt.primary {
+				t.Errorf("resumed run left weights: %+v canary: %+v primary: %+v", resumedClient.weights, resumedClient.pools["canary"], resumedClient.pools["primary"])
+			}
+
+			if len(pushes) != 1 || !strings.Contains(pushes[0], "/service/app") || !strings.Contains(pushes[0], "/workflow/test") {
+				t.Errorf("resumed run pushed metrics to %v, want a single push for app test", pushes)
+			}
+		})
+	}
+}
+
+func TestProcessor_hold(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision Decision
+		deadline time.Duration
+		want     string
+	}{
+		{name: "continue", decision: ContinueRun, want: ""},
+		{name: "abort", decision: AbortRun, want: "Aborted"},
+		{name: "deadline", deadline: -time.Minute, want: "Auto-abort deadline passed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, cleanup := newTestStateStore(t)
+			defer cleanup()
+
+			p := newTestProcessor(&config.Workflow{}, newFakeClient(config.ServiceState{}, config.ServiceState{}))
+			p.workflow.Config.Services["app"].PollInterval = time.Millisecond
+			p.state = store
+
+			paused := &PausedRun{Run: p.Status(), Hold: true, PausedAt: time.Now()}
+			if tt.deadline != 0 {
+				paused.Deadline = paused.PausedAt.Add(tt.deadline)
+			}
+
+			decided := *paused
+			decided.Decision = tt.decision
+			decided.Reason = tt.want
+			if err := store.Save(&decided); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := p.hold(paused); got != tt.want {
+				t.Errorf("hold() = %q, want %q", got, tt.want)
+			}
+
+			if remaining, _ := store.Load(paused.Run.ID); remaining != nil {
+				t.Errorf("hold() left the paused run %+v in the store", remaining)
+			}
+		})
+	}
+}
+
+func TestAbort_heldExpired(t *testing.T) {
+	store, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	cfg := &config.Config{
+		Services: map[string]*config.ServiceConfig{"app": {}},
+		State:    &config.StateConfig{Dir: store.Dir},
+	}
+
+	paused := &PausedRun{
+		Run:      &Run{ID: "run1", Service: "app"},
+		Hold:     true,
+		PausedAt: time.Now().Add(-time.Hour),
+		Deadline: time.Now().Add(-time.Minute),
+	}
+	if err := store.Save(paused); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Abort(cfg, "run1", "Aborted"); err != nil {
+		t.Fatalf("Abort() error = %v", err)
+	}
+
+	saved, err := store.Load("run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved == nil || saved.Decision != AbortRun || saved.Reason != "Aborted" {
+		t.Errorf("Abort() saved %+v, want the held run marked aborted for its holder to roll back", saved)
+	}
+}
@@ -0,0 +1,255 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+// rollbackAttempts how many times each rollback operation is tried before the rollback gives up
+const rollbackAttempts = 3
+
+// rollbackRetryDelay how long to wait before retrying a failed rollback operation
+var rollbackRetryDelay = 10 * time.Second
+
+// RollbackError a rollback that did not restore its checkpoint, leaving the service in a state that needs a human
+type RollbackError struct {
+	// Reason why the workflow was rolled back
+	Reason string
+	Err    error
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%s: rollback failed: %s", e.Reason, e.Err)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// RollbackSteps returns the steps restoring taskDef to both pools, deploying each pool only while it receives no traffic
+// and validating it with the services validation task before traffic returns to it
+func RollbackSteps(taskDef string, canaryCount, primaryCount int64, validate bool) []*config.Action {
+	if canaryCount < 1 {
+		canaryCount = 1
+	}
+
+	steps := []*config.Action{
+		{Type: config.TrafficShift, Target: "primary", Ratio: 100},
+		{Type: config.UpdatePool, Target: "canary", Task: taskDef, Count: canaryCount},
+	}
+
+	if validate {
+		steps = append(steps, &config.Action{Type: config.ValidatePool, Target: "task"})
+	}
+
+	steps = append(steps,
+		&config.Action{Type: config.TrafficShift, Target: "canary", Ratio: 100, PreScale: true},
+		&config.Action{Type: config.UpdatePool, Target: "primary", Task: taskDef, Count: primaryCount},
+	)
+
+	if validate {
+		steps = append(steps, &config.Action{Type: config.ValidatePool, Target: "task"})
+	}
+
+	return append(steps,
+		&config.Action{Type: config.TrafficShift, Target: "primary", Ratio: 100},
+		&config.Action{Type: config.UpdatePool, Target: "canary", Task: taskDef, Count: canaryCount},
+	)
+}
+
+// rollbackToLatestCheckpoint reverts only the pools and weights the workflow changed. A pool still receiving traffic is
+// drained to the other pool before it is redeployed, as long as that pool is already in its checkpoint state and can be
+// scaled up to serve all traffic healthily, otherwise it is redeployed in place. The checkpoint weights are restored
+// last and the drained onto pool scaled back. Every operation is retried and the final state is verified against the
+// checkpoint
+func (p *Processor) rollbackToLatestCheckpoint() error {
+	service := p.workflow.Service
+	target := p.rollbackTarget()
+	if target.Weights == nil || target.Canary == nil || target.Primary == nil {
+		return fmt.Errorf("checkpoint is incomplete: %+v", target)
+	}
+
+	current := p.captureState()
+	if current.Weights == nil || current.Canary == nil || current.Primary == nil {
+		return fmt.Errorf("failed to read the current state")
+	}
+
+	changed := p.changedPools(target, current)
+	pending := map[string]bool{}
+	for _, pool := range changed {
+		pending[pool] = true
+	}
+
+	drainedOnto := []string{}
+	for _, pool := range changed {
+		other := otherPool(pool)
+		if poolWeight(current.Weights, pool) > 0 && !pending[other] && p.prepareDrain(other) {
+			drained := &config.ServiceWeights{}
+			setPoolWeight(drained, other, 100)
+
+			err := p.retryRollback(fmt.Sprintf("shift traffic from %s to %s", pool, other), func() error {
+				return p.client.UpdateWeights(service, drained)
+			})
+			if err != nil {
+				return err
+			}
+			current.Weights = drained
+			drainedOnto = append(drainedOnto, other)
+		}
+
+		state := target.Canary
+		if pool == "primary" {
+			state = target.Primary
+		}
+
+		err := p.retryRollback(fmt.Sprintf("redeploy %s to %s", pool, state.TaskDef), func() error {
+			if !p.client.Deploy(service, pool, state) {
+				return fmt.Errorf("deployment failed")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		pending[pool] = false
+	}
+
+	if *current.Weights != *target.Weights {
+		err := p.retryRollback("restore weights", func() error {
+			return p.client.UpdateWeights(service, target.Weights)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pool := range drainedOnto {
+		state := target.Canary
+		if pool == "primary" {
+			state = target.Primary
+		}
+
+		err := p.retryRollback(fmt.Sprintf("scale %s back to %d tasks", pool, state.Count), func() error {
+			if !p.scalePool(pool, state.Count, false) {
+				return fmt.Errorf("scaling failed")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.verifyRollback(target)
+}
+
+// prepareDrain scales the pool up to serve all traffic and checks it is healthy, returning false when traffic can't
+// safely be drained onto it
+func (p *Processor) prepareDrain(pool string) bool {
+	reference, err := p.getFullTrafficCount()
+	if err != nil {
+		p.log.Warnf("[workflow.rollbackToLatestCheckpoint] Redeploying in place, %s", err)
+		return false
+	}
+
+	if !p.scalePool(pool, requiredCount(reference, 100), true) {
+		p.log.Warnf("[workflow.rollbackToLatestCheckpoint] Redeploying in place, failed to scale up pool: %s", pool)
+		return false
+	}
+
+	if !p.isPoolHealthy(pool, p.workflow.Config.GetHealthCheck(p.workflow.Service)) {
+		p.log.Warnf("[workflow.rollbackToLatestCheckpoint] Redeploying in place, pool is unhealthy: %s", pool)
+		return false
+	}
+
+	return true
+}
+
+// changedPools returns the pools whose state differs from the checkpoint, the pool with the least traffic first
+func (p *Processor) changedPools(target, current *Checkpoint) []string {
+	changed := []string{}
+	if p.poolChanged("canary", target.Canary, current.Canary) {
+		changed = append(changed, "canary")
+	}
+	if p.poolChanged("primary", target.Primary, current.Primary) {
+		changed = append(changed, "primary")
+	}
+
+	sort.SliceStable(changed, func(i, j int) bool {
+		return poolWeight(current.Weights, changed[i]) < poolWeight(current.Weights, changed[j])
+	})
+
+	return changed
+}
+
+// poolChanged compares task definitions and, unless auto scaling manages the pool, task counts
+func (p *Processor) poolChanged(pool string, target, current *config.ServiceState) bool {
+	if current == nil {
+		return true
+	}
+
+	if target.TaskDef != current.TaskDef {
+		return true
+	}
+
+	return p.scaling[pool] == nil && target.Count != current.Count
+}
+
+// verifyRollback checks the service now matches the checkpoint
+func (p *Processor) verifyRollback(target *Checkpoint) error {
+	final := p.captureState()
+
+	mismatches := []string{}
+	if final.Weights == nil || *final.Weights != *target.Weights {
+		mismatches = append(mismatches, fmt.Sprintf("weights: %+v want: %+v", final.Weights, target.Weights))
+	}
+	if p.poolChanged("canary", target.Canary, final.Canary) {
+		mismatches = append(mismatches, fmt.Sprintf("canary: %+v want: %+v", final.Canary, target.Canary))
+	}
+	if p.poolChanged("primary", target.Primary, final.Primary) {
+		mismatches = append(mismatches, fmt.Sprintf("primary: %+v want: %+v", final.Primary, target.Primary))
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("state does not match the checkpoint, %s", strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
+
+// retryRollback runs a rollback operation until it succeeds or runs out of attempts
+func (p *Processor) retryRollback(operation string, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= rollbackAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		p.log.Warnf("[workflow.rollbackToLatestCheckpoint] %s failed attempt: %d of %d: %s", operation, attempt, rollbackAttempts, err)
+		if attempt < rollbackAttempts {
+			time.Sleep(rollbackRetryDelay)
+		}
+	}
+
+	return fmt.Errorf("%s: %s", operation, err)
+}
+
+func otherPool(pool string) string {
+	if pool == "canary" {
+		return "primary"
+	}
+
+	return "canary"
+}
+
+func setPoolWeight(weights *config.ServiceWeights, pool string, weight int64) {
+	if pool == "canary" {
+		weights.Canary = weight
+	} else {
+		weights.Primary = weight
+	}
+}
@@ -0,0 +1,260 @@
+package workflow
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+type (
+	// RunStatus the state of a workflow run or one of its steps
+	RunStatus string
+
+	// Run the record of a single workflow execution
+	Run struct {
+		ID         string            `json:"id"`
+		Service    string            `json:"service"`
+		Workflow   string            `json:"workflow"`
+		Parameters map[string]string `json:"parameters,omitempty"`
+		// OverrideReason why deploy windows and freezes were overridden, Overridden the closed window or freeze that was
+		OverrideReason string      `json:"overrideReason,omitempty"`
+		Overridden     string      `json:"overridden,omitempty"`
+		Status         RunStatus   `json:"status"`
+		Error          string      `json:"error,omitempty"`
+		StartedAt      time.Time   `json:"startedAt"`
+		FinishedAt     time.Time   `json:"finishedAt,omitempty"`
+		Checkpoint     *Checkpoint `json:"checkpoint,omitempty"`
+		// Checkpoints every checkpoint of the run starting with the state before the first step
+		Checkpoints    []*CheckpointRecord   `json:"checkpoints,omitempty"`
+		RollbackPolicy config.RollbackPolicy `json:"rollbackPolicy,omitempty"`
+		Steps          []*StepRecord         `json:"steps"`
+		Rollback       *RollbackRecord       `json:"rollback,omitempty"`
+	}
+
+	// StepRecord the history of a single step of a run
+	StepRecord struct {
+		Index      int            `json:"index"`
+		Action     *config.Action `json:"action"`
+		Status     RunStatus      `json:"status"`
+		Error      string         `json:"error,omitempty"`
+		Result     string         `json:"result,omitempty"`
+		Attempts   int            `json:"attempts,omitempty"`
+		StartedAt  time.Time      `json:"startedAt"`
+		FinishedAt time.Time      `json:"finishedAt,omitempty"`
+		Before     *Checkpoint    `json:"before,omitempty"`
+		After      *Checkpoint    `json:"after,omitempty"`
+	}
+
+	// CheckpointRecord a state the run could roll back to and the step that captured it, 0 for the start of the run
+	CheckpointRecord struct {
+		Step      int         `json:"step"`
+		CreatedAt time.Time   `json:"createdAt"`
+		State     *Checkpoint `json:"state"`
+	}
+
+	// RollbackRecord a rollback performed by the run
+	RollbackRecord struct {
+		Reason     string      `json:"reason"`
+		StartedAt  time.Time   `json:"startedAt"`
+		FinishedAt time.Time   `json:"finishedAt,omitempty"`
+		Target     *Checkpoint `json:"target"`
+		After      *Checkpoint `json:"after,omitempty"`
+		Error      string      `json:"error,omitempty"`
+	}
+
+	// runRecord guards a run shared between the processor and its observers
+	runRecord struct {
+		mu  sync.Mutex
+		run *Run
+	}
+)
+
+const (
+	RunPending    RunStatus = "pending"
+	RunRunning    RunStatus = "running"
+	RunSucceeded  RunStatus = "succeeded"
+	RunFailed     RunStatus = "failed"
+	RunCancelled  RunStatus = "cancelled"
+	RunRolledBack RunStatus = "rolled-back"
+	// RunRollbackFailed the rollback did not restore the checkpoint and the service needs attention
+	RunRollbackFailed RunStatus = "rollback-failed"
+	RunSkipped        RunStatus = "skipped"
+	// RunBlocked the run did not start because the service is frozen or outside of its deploy windows
+	RunBlocked RunStatus = "blocked"
+	// RunPaused the run stopped at a pause step until it is continued or aborted
+	RunPaused RunStatus = "paused"
+)
+
+func newRunRecord(workflow *config.Workflow) *runRecord {
+	return &runRecord{
+		run: &Run{
+			ID:             newRunID(),
+			Service:        workflow.Service,
+			Workflow:       workflow.Name,
+			Parameters:     workflow.Parameters,
+			OverrideReason: workflow.OverrideReason,
+			RollbackPolicy: workflow.RollbackPolicy,
+			Status:         RunPending,
+			Steps:          []*StepRecord{},
+		},
+	}
+}
+
+func (r *runRecord) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Status = RunRunning
+	r.run.StartedAt = time.Now()
+}
+
+func (r *runRecord) pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Status = RunPaused
+	r.run.FinishedAt = time.Now()
+}
+
+func (r *runRecord) resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Status = RunRunning
+	r.run.FinishedAt = time.Time{}
+}
+
+func (r *runRecord) finish(status RunStatus, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Status = status
+	r.run.FinishedAt = time.Now()
+	if err != nil {
+		r.run.Error = err.Error()
+	}
+}
+
+func (r *runRecord) setOverrideReason(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.OverrideReason = reason
+}
+
+// override records a closed deploy window or freeze the run went ahead despite, keeping the first
+func (r *runRecord) override(overridden string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.run.Overridden == "" {
+		r.run.Overridden = overridden
+	}
+}
+
+func (r *runRecord) setCheckpoint(checkpoint *Checkpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Checkpoint = checkpoint
+}
+
+func (r *runRecord) addCheckpoint(step int, state *Checkpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Checkpoints = append(r.run.Checkpoints, &CheckpointRecord{
+		Step:      step,
+		CreatedAt: time.Now(),
+		State:     state,
+	})
+}
+
+func (r *runRecord) startRollback(reason string, target *Checkpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Rollback = &RollbackRecord{
+		Reason:    reason,
+		StartedAt: time.Now(),
+		Target:    target,
+	}
+}
+
+func (r *runRecord) finishRollback(after *Checkpoint, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.Rollback.FinishedAt = time.Now()
+	r.run.Rollback.After = after
+	if err != nil {
+		r.run.Rollback.Error = err.Error()
+	}
+}
+
+func (r *runRecord) startStep(index int, action *config.Action, before *Checkpoint) *StepRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	step := &StepRecord{
+		Index:     index,
+		Action:    action,
+		Status:    RunRunning,
+		StartedAt: time.Now(),
+		Before:    before,
+	}
+	r.run.Steps = append(r.run.Steps, step)
+
+	return step
+}
+
+func (r *runRecord) attempt(step *StepRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	step.Attempts++
+}
+
+func (r *runRecord) finishStep(step *StepRecord, status RunStatus, reason, result string, after *Checkpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	step.Status = status
+	step.Error = reason
+	step.Result = result
+	step.FinishedAt = time.Now()
+	step.After = after
+}
+
+// snapshot returns a copy of the run safe to read while the workflow continues
+func (r *runRecord) snapshot() *Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run := *r.run
+	run.Checkpoints = append([]*CheckpointRecord{}, r.run.Checkpoints...)
+	run.Steps = make([]*StepRecord, len(r.run.Steps))
+	for i, step := range r.run.Steps {
+		copied := *step
+		run.Steps[i] = &copied
+	}
+
+	if r.run.Rollback != nil {
+		rollback := *r.run.Rollback
+		run.Rollback = &rollback
+	}
+
+	return &run
+}
+
+func newRunID() string {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,300 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/chriskuchin/pompeii/config"
+)
+
+type (
+	// Decision what pompeii continue or abort asked of a held run
+	Decision string
+
+	// PausedRun everything needed to resume a run after its pause step
+	PausedRun struct {
+		Run          *Run                            `json:"run"`
+		Default      *config.ServiceState            `json:"default,omitempty"`
+		PoolDefaults map[string]*config.ServiceState `json:"poolDefaults,omitempty"`
+		Steps        []*config.Action                `json:"steps"`
+		// Next the index of the step the run resumes at
+		Next     int       `json:"next"`
+		Hold     bool      `json:"hold,omitempty"`
+		PausedAt time.Time `json:"pausedAt"`
+		Deadline time.Time `json:"deadline,omitempty"`
+		Decision Decision  `json:"decision,omitempty"`
+		Reason   string    `json:"reason,omitempty"`
+		// OverrideReason why the continued run may override deploy windows and freezes
+		OverrideReason string `json:"overrideReason,omitempty"`
+	}
+
+	// StateStore persists paused runs
+	StateStore interface {
+		Save(paused *PausedRun) error
+		// Load returns the paused run or nil when no run with the id is paused
+		Load(id string) (*PausedRun, error)
+		Delete(id string) error
+		// Claim takes the paused run out of the store for the one caller resuming it, every other caller fails
+		Claim(paused *PausedRun) error
+		List() ([]*PausedRun, error)
+	}
+
+	// LocalStateStore keeps paused runs as json files in <Dir>/
+	LocalStateStore struct {
+		Dir string
+	}
+
+	// S3StateStore keeps paused runs as json objects under <Prefix>/
+	S3StateStore struct {
+		Bucket string
+		Prefix string
+		Svc    s3iface.S3API
+	}
+)
+
+const (
+	ContinueRun Decision = "continue"
+	AbortRun    Decision = "abort"
+)
+
+// NewStateStore returns the store paused runs are kept in
+func NewStateStore(cfg *config.Config) StateStore {
+	state := cfg.GetStateConfig()
+	if state.Dir != "" {
+		return &LocalStateStore{Dir: state.Dir}
+	}
+
+	awsConfig := &aws.Config{}
+	if cfg.Region != "" {
+		awsConfig.Region = aws.String(cfg.Region)
+	}
+
+	return &S3StateStore{
+		Bucket: state.Bucket,
+		Prefix: state.Prefix,
+		Svc:    s3.New(session.New(awsConfig)),
+	}
+}
+
+// Expired whether the run has waited past its deadline
+func (r *PausedRun) Expired(now time.Time) bool {
+	return !r.Deadline.IsZero() && now.After(r.Deadline)
+}
+
+// Save implements StateStore
+func (s *LocalStateStore) Save(paused *PausedRun) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(paused, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.Dir, paused.Run.ID+".json"), raw, 0644)
+}
+
+// Load implements StateStore
+func (s *LocalStateStore) Load(id string) (*PausedRun, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(s.Dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePausedRun(raw)
+}
+
+// Delete implements StateStore
+func (s *LocalStateStore) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.Dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Claim implements StateStore, removing the file is atomic so only one caller finds it
+func (s *LocalStateStore) Claim(paused *PausedRun) error {
+	err := os.Remove(filepath.Join(s.Dir, paused.Run.ID+".json"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("run %s was already resumed", paused.Run.ID)
+	}
+
+	return err
+}
+
+// List implements StateStore
+func (s *LocalStateStore) List() ([]*PausedRun, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*PausedRun{}
+	for _, p := range paths {
+		raw, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		paused, err := decodePausedRun(raw)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, paused)
+	}
+
+	sortPausedRuns(runs)
+	return runs, nil
+}
+
+// Save implements StateStore
+func (s *S3StateStore) Save(paused *PausedRun) error {
+	raw, err := json.Marshal(paused)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Svc.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.key(paused.Run.ID)),
+		Body:        bytes.NewReader(raw),
+		ContentType: aws.String("application/json"),
+	})
+
+	return err
+}
+
+// Load implements StateStore
+func (s *S3StateStore) Load(id string) (*PausedRun, error) {
+	return s.get(s.key(id))
+}
+
+// Delete implements StateStore
+func (s *S3StateStore) Delete(id string) error {
+	_, err := s.Svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(id)),
+	})
+
+	return err
+}
+
+// Claim implements StateStore. Deleting an object succeeds for every caller, so the pause is claimed by creating a
+// claim object that only the first conditional write succeeds at. Claims are kept per pause, a run that pauses again
+// at a later step is claimed afresh
+func (s *S3StateStore) Claim(paused *PausedRun) error {
+	req, _ := s.Svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path.Join(s.Prefix, "claims", fmt.Sprintf("%s-%d", paused.Run.ID, paused.Next))),
+		Body:   bytes.NewReader([]byte{}),
+	})
+	req.HTTPRequest.Header.Set("If-None-Match", "*")
+
+	if err := req.Send(); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "PreconditionFailed" || aerr.Code() == "ConditionalRequestConflict") {
+			return fmt.Errorf("run %s was already resumed", paused.Run.ID)
+		}
+		return err
+	}
+
+	return s.Delete(paused.Run.ID)
+}
+
+// List implements StateStore
+func (s *S3StateStore) List() ([]*PausedRun, error) {
+	keys := []string{}
+	err := s.Svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.Prefix + "/"),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			// claims are kept alongside the paused runs
+			if key := aws.StringValue(object.Key); strings.HasSuffix(key, ".json") {
+				keys = append(keys, key)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*PausedRun{}
+	for _, key := range keys {
+		paused, err := s.get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if paused != nil {
+			runs = append(runs, paused)
+		}
+	}
+
+	sortPausedRuns(runs)
+	return runs, nil
+}
+
+func (s *S3StateStore) get(key string) (*PausedRun, error) {
+	result, err := s.Svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	raw, err := ioutil.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePausedRun(raw)
+}
+
+func (s *S3StateStore) key(id string) string {
+	return path.Join(s.Prefix, id+".json")
+}
+
+func decodePausedRun(raw []byte) (*PausedRun, error) {
+	paused := &PausedRun{}
+	if err := json.Unmarshal(raw, paused); err != nil {
+		return nil, err
+	}
+
+	if paused.Run == nil {
+		return nil, fmt.Errorf("paused run is missing its run record")
+	}
+
+	return paused, nil
+}
+
+// sortPausedRuns orders paused runs oldest first
+func sortPausedRuns(runs []*PausedRun) {
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].PausedAt.Before(runs[j].PausedAt)
+	})
+}
@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestLocalStateStore_Claim(t *testing.T) {
+	store, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	paused := &PausedRun{Run: &Run{ID: "run1"}, Next: 3}
+	if err := store.Save(paused); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Claim(paused); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+
+	if err := store.Claim(paused); err == nil || !strings.Contains(err.Error(), "already resumed") {
+		t.Errorf("second Claim() error = %v, want already resumed", err)
+	}
+
+	if remaining, _ := store.Load("run1"); remaining != nil {
+		t.Errorf("Claim() left the paused run %+v in the store", remaining)
+	}
+}
+
+func TestS3StateStore_Claim(t *testing.T) {
+	var mu sync.Mutex
+	claims := map[string]bool{}
+	deleted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("If-None-Match") != "*" {
+				t.Errorf("claim written without If-None-Match")
+			}
+			if claims[r.URL.Path] {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`))
+				return
+			}
+			claims[r.URL.Path] = true
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store := &S3StateStore{
+		Bucket: "bucket",
+		Prefix: "paused",
+		Svc: s3.New(session.Must(session.NewSession(&aws.Config{
+			Endpoint:         aws.String(server.URL),
+			Region:           aws.String("us-east-1"),
+			S3ForcePathStyle: aws.Bool(true),
+			Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		}))),
+	}
+
+	paused := &PausedRun{Run: &Run{ID: "run1"}, Next: 3}
+	if err := store.Claim(paused); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+
+	if err := store.Claim(paused); err == nil || !strings.Contains(err.Error(), "already resumed") {
+		t.Errorf("second Claim() error = %v, want already resumed", err)
+	}
+
+	// pausing again at a later step is a new pause to claim
+	paused.Next = 5
+	if err := store.Claim(paused); err != nil {
+		t.Errorf("Claim() of a later pause error = %v", err)
+	}
+
+	if !claims["/bucket/paused/claims/run1-3"] || len(deleted) != 2 || deleted[0] != "/bucket/paused/run1.json" {
+		t.Errorf("Claim() claims: %v deleted: %v", claims, deleted)
+	}
+}
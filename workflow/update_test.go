@@ -0,0 +1,283 @@
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/logging"
+)
+
+type (
+	// fakeClient keeps the service state in memory, recording every deployment
+	fakeClient struct {
+		weights  *config.ServiceWeights
+		pools    map[string]*config.ServiceState
+		scaling  map[string]*config.ScalingState
+		deployed []*deployment
+		scaled   []*deployment
+		// onDeploy when set is called before every deployment
+		onDeploy func()
+		// failDeploys the number of deployments to fail before deploying again
+		failDeploys int
+		// scalingErr when set is returned when reading the scaling state
+		scalingErr error
+	}
+
+	deployment struct {
+		pool  string
+		state config.ServiceState
+	}
+)
+
+func newFakeClient(canary, primary config.ServiceState) *fakeClient {
+	return &fakeClient{
+		weights: &config.ServiceWeights{Primary: 100},
+		pools: map[string]*config.ServiceState{
+			"canary":  &canary,
+			"primary": &primary,
+		},
+		scaling: map[string]*config.ScalingState{},
+	}
+}
+
+// newTestProcessor returns a processor for steps that runs against the fake client
+func newTestProcessor(workflow *config.Workflow, client *fakeClient) *Processor {
+	workflow.Config = &config.Config{Services: map[string]*config.ServiceConfig{"app": {}}}
+	workflow.Service = "app"
+	workflow.Name = "test"
+
+	p, err := NewProcessor(workflow)
+	if err != nil {
+		panic(err)
+	}
+	p.client = client
+
+	return p
+}
+
+func (f *fakeClient) GetCurrentWeights(service string) *config.ServiceWeights {
+	weights := *f.weights
+	return &weights
+}
+
+func (f *fakeClient) UpdateWeights(service string, weights *config.ServiceWeights) error {
+	updated := *weights
+	f.weights = &updated
+	return nil
+}
+
+func (f *fakeClient) GetTargetHealth(service, pool string) (*config.TargetHealth, error) {
+	if f.pools[pool] == nil {
+		return nil, fmt.Errorf("unknown pool: %s", pool)
+	}
+
+	return &config.TargetHealth{Healthy: f.pools[pool].Count, Total: f.pools[pool].Count}, nil
+}
+
+func (f *fakeClient) GetCurrentServiceState(service, pool string) *config.ServiceState {
+	if f.pools[pool] == nil {
+		return nil
+	}
+
+	state := *f.pools[pool]
+	return &state
+}
+
+func (f *fakeClient) Deploy(service, pool string, state *config.ServiceState) bool {
+	if f.onDeploy != nil {
+		f.onDeploy()
+	}
+
+	if f.failDeploys > 0 {
+		f.failDeploys--
+		return false
+	}
+
+	f.deployed = append(f.deployed, &deployment{pool: pool, state: *state})
+
+	updated := *state
+	f.pools[pool] = &updated
+	return true
+}
+
+func (f *fakeClient) Scale(service, pool string, count int64) bool {
+	f.scaled = append(f.scaled, &deployment{pool: pool, state: config.ServiceState{Count: count}})
+	f.pools[pool].Count = count
+	return true
+}
+
+func (f *fakeClient) GetScalingState(service, pool string) (*config.ScalingState, error) {
+	if f.scalingErr != nil {
+		return nil, f.scalingErr
+	}
+
+	return f.scaling[pool], nil
+}
+
+func (f *fakeClient) UpdateScalingState(service, pool string, state *config.ScalingState) error {
+	f.scaling[pool] = state
+	return nil
+}
+
+func (f *fakeClient) StartAndMonitorTask(service, task, container string, command []string) bool {
+	return false
+}
+
+func (f *fakeClient) SetLogger(logger *logging.Entry) {}
+
+func TestProcessor_Run_updates(t *testing.T) {
+	canary := config.ServiceState{TaskDef: "app:1", Count: 1}
+	primary := config.ServiceState{TaskDef: "app:1", Count: 4}
+
+	tests := []struct {
+		name         string
+		defaults     *config.ServiceState
+		poolDefaults map[string]*config.ServiceState
+		steps        []*config.Action
+		scaling      map[string]*config.ScalingState
+		want         []*deployment
+	}{
+		{
+			name:     "action_count_is_not_shared",
+			defaults: &config.ServiceState{TaskDef: "app:2", Count: 3},
+			steps: []*config.Action{
+				{Type: config.UpdatePool, Target: "canary", Count: 1},
+				{Type: config.UpdatePool, Target: "primary"},
+			},
+			want: []*deployment{
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:2", Count: 1}},
+				{pool: "primary", state: config.ServiceState{TaskDef: "app:2", Count: 3}},
+			},
+		},
+		{
+			name:     "action_task_is_not_shared",
+			defaults: &config.ServiceState{TaskDef: "app:2", Count: 3},
+			steps: []*config.Action{
+				{Type: config.UpdatePool, Target: "canary", Task: "app:debug"},
+				{Type: config.UpdatePool, Target: "primary"},
+				{Type: config.UpdatePool, Target: "canary"},
+			},
+			want: []*deployment{
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:debug", Count: 3}},
+				{pool: "primary", state: config.ServiceState{TaskDef: "app:2", Count: 3}},
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:2", Count: 3}},
+			},
+		},
+		{
+			name:     "pool_defaults",
+			defaults: &config.ServiceState{TaskDef: "app:2", Count: 3},
+			poolDefaults: map[string]*config.ServiceState{
+				"canary": {Count: 1},
+			},
+			steps: []*config.Action{
+				{Type: config.UpdatePool, Target: "canary"},
+				{Type: config.UpdatePool, Target: "primary"},
+				{Type: config.UpdatePool, Target: "canary", Count: 2},
+			},
+			want: []*deployment{
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:2", Count: 1}},
+				{pool: "primary", state: config.ServiceState{TaskDef: "app:2", Count: 3}},
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:2", Count: 2}},
+			},
+		},
+		{
+			name:     "keep_current_count",
+			defaults: &config.ServiceState{TaskDef: "app:2"},
+			steps: []*config.Action{
+				{Type: config.UpdatePool, Target: "canary"},
+				{Type: config.UpdatePool, Target: "primary"},
+			},
+			want: []*deployment{
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:2", Count: 1}},
+				{pool: "primary", state: config.ServiceState{TaskDef: "app:2", Count: 4}},
+			},
+		},
+		{
+			name:     "auto_scaled_pool_keeps_count",
+			defaults: &config.ServiceState{TaskDef: "app:2", Count: 3},
+			scaling:  map[string]*config.ScalingState{"primary": {MinCapacity: 2, MaxCapacity: 8}},
+			steps: []*config.Action{
+				{Type: config.UpdatePool, Target: "canary"},
+				{Type: config.UpdatePool, Target: "primary"},
+			},
+			want: []*deployment{
+				{pool: "canary", state: config.ServiceState{TaskDef: "app:2", Count: 3}},
+				{pool: "primary", state: config.ServiceState{TaskDef: "app:2", Count: 4}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(canary, primary)
+			for pool, state := range tt.scaling {
+				client.scaling[pool] = state
+			}
+
+			defaults := *tt.defaults
+			p := newTestProcessor(&config.Workflow{
+				Default:      &defaults,
+				PoolDefaults: tt.poolDefaults,
+				Steps:        tt.steps,
+			}, client)
+
+			if err := p.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(client.deployed, tt.want) {
+				for i, deployed := range client.deployed {
+					t.Logf("deployed[%d] = %s %+v", i, deployed.pool, deployed.state)
+				}
+				t.Errorf("Run() deployed %d updates, want %+v", len(client.deployed), tt.want)
+			}
+
+			if defaults != *tt.defaults {
+				t.Errorf("Run() changed the workflow default to %+v, want %+v", defaults, *tt.defaults)
+			}
+		})
+	}
+}
+
+func TestProcessor_Run_scaleDownRamp(t *testing.T) {
+	client := newFakeClient(config.ServiceState{TaskDef: "app:2", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 10})
+	p := newTestProcessor(&config.Workflow{
+		Steps: []*config.Action{
+			{Type: config.TrafficShift, Target: "canary", Ratio: 50, PreScale: true, ScaleDown: true},
+			{Type: config.TrafficShift, Target: "canary", Ratio: 100, PreScale: true, ScaleDown: true},
+		},
+	}, client)
+
+	if err := p.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []*deployment{
+		{pool: "canary", state: config.ServiceState{Count: 5}},
+		{pool: "primary", state: config.ServiceState{Count: 5}},
+		{pool: "canary", state: config.ServiceState{Count: 10}},
+		{pool: "primary", state: config.ServiceState{Count: 0}},
+	}
+	if !reflect.DeepEqual(client.scaled, want) {
+		for i, scaled := range client.scaled {
+			t.Logf("scaled[%d] = %s %d", i, scaled.pool, scaled.state.Count)
+		}
+		t.Errorf("Run() scaled %d times, want %+v", len(client.scaled), want)
+	}
+}
+
+func TestProcessor_scalePool_unknownState(t *testing.T) {
+	client := newFakeClient(config.ServiceState{}, config.ServiceState{})
+	delete(client.pools, "canary")
+
+	p := newTestProcessor(&config.Workflow{}, client)
+	if p.scalePool("canary", 2, true) {
+		t.Error("scalePool() = true, want false when the pool state can't be read")
+	}
+
+	p.checkpoint = &Checkpoint{}
+	if _, err := p.getFullTrafficCount(); err == nil {
+		t.Error("getFullTrafficCount() error = nil, want an error when the pool state can't be read")
+	}
+}
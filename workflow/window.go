@@ -0,0 +1,45 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chriskuchin/pompeii/config"
+	"github.com/chriskuchin/pompeii/notify"
+)
+
+// checkDeployWindow returns why the service may not be deployed now, or "" when it may. A closed window or freeze is
+// let through and recorded when the workflow overrides them, and never holds back a rollback
+func (p *Processor) checkDeployWindow() string {
+	if p.workflow.Rollback {
+		return ""
+	}
+
+	err := p.workflow.Config.CheckDeployWindow(p.workflow.Service, time.Now())
+	if err == nil {
+		return ""
+	}
+
+	if p.workflow.OverrideReason == "" {
+		return err.Error()
+	}
+
+	if p.record.snapshot().Overridden == "" {
+		p.log.Warnf("[workflow.checkDeployWindow] Overriding: %s reason: %s", err, p.workflow.OverrideReason)
+		p.notify(notify.FreezeOverridden, 0, nil, fmt.Sprintf("%s, overridden: %s", err, p.workflow.OverrideReason))
+	}
+	p.record.override(err.Error())
+
+	return ""
+}
+
+// block ends a run that may not start because the service is frozen or outside of its deploy windows
+func (p *Processor) block(step int, action *config.Action, reason string) error {
+	p.log.Errorf("Not starting: %s", reason)
+
+	err := fmt.Errorf("Not starting: %s", reason)
+	p.record.finish(RunBlocked, err)
+	p.notify(notify.WorkflowBlocked, step, action, reason)
+
+	return err
+}
@@ -0,0 +1,46 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/chriskuchin/pompeii/config"
+)
+
+func TestProcessor_Run_freeze(t *testing.T) {
+	freeze := &config.Freeze{Name: "always", Start: "2000-01-01", End: "2999-12-31"}
+
+	tests := []struct {
+		name     string
+		override string
+		rollback bool
+		want     RunStatus
+		deployed int
+	}{
+		{name: "blocked", want: RunBlocked},
+		{name: "overridden", override: "hotfix for incident 42", want: RunSucceeded, deployed: 1},
+		{name: "rollback", rollback: true, want: RunSucceeded, deployed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(config.ServiceState{TaskDef: "app:1", Count: 1}, config.ServiceState{TaskDef: "app:1", Count: 4})
+			p := newTestProcessor(&config.Workflow{
+				OverrideReason: tt.override,
+				Rollback:       tt.rollback,
+				Default:        &config.ServiceState{TaskDef: "app:2"},
+				Steps:          []*config.Action{{Type: config.UpdatePool, Target: "canary"}},
+			}, client)
+			p.workflow.Config.Services["app"].Freezes = []*config.Freeze{freeze}
+
+			err := p.Run()
+
+			run := p.Status()
+			if run.Status != tt.want || len(client.deployed) != tt.deployed {
+				t.Fatalf("Run() status = %s deployed: %d error: %v, want %s deployed: %d", run.Status, len(client.deployed), err, tt.want, tt.deployed)
+			}
+
+			if run.OverrideReason != tt.override || (tt.override != "") != (run.Overridden != "") {
+				t.Errorf("Run() override = %q overridden: %q, want %q", run.OverrideReason, run.Overridden, tt.override)
+			}
+		})
+	}
+}